@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gxespino/ctree/internal/history"
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// runHistory prints status timelines for recent sessions.
+// Usage: ctree history [--session ID] [--since 2h]
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	session := fs.String("session", "", "only show the session whose ID starts with this prefix")
+	sinceFlag := fs.String("since", "24h", "how far back to look (e.g. 90m, 2h, 3d)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	lookback, err := history.ParseSince(*sinceFlag)
+	if err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+	since := time.Now().Add(-lookback)
+
+	shown := 0
+	for _, log := range hookdata.ListHistory() {
+		if *session != "" && !strings.HasPrefix(log.ID, *session) {
+			continue
+		}
+		if log.ModTime.Before(since) {
+			continue
+		}
+
+		events, err := hookdata.ReadHistory(log.ID, since)
+		if err != nil {
+			return err
+		}
+		spans := history.Timeline(events)
+		if len(spans) == 0 {
+			continue
+		}
+
+		if shown > 0 {
+			fmt.Println()
+		}
+		printTimeline(log.ID, events, spans)
+		shown++
+	}
+
+	if shown == 0 {
+		fmt.Printf("No session history in the last %s.\n", *sinceFlag)
+	}
+	return nil
+}
+
+// printTimeline writes one session's header, spans and totals.
+func printTimeline(id string, events []hookdata.Event, spans []history.Span) {
+	var cwd string
+	for _, ev := range events {
+		if ev.CWD != "" {
+			cwd = ev.CWD
+		}
+	}
+
+	header := id
+	if cwd != "" {
+		header += "  " + cwd
+	}
+	last := events[len(events)-1].Timestamp
+	fmt.Printf("%s  (%s)\n", header, model.RelativeTime(last))
+
+	for _, s := range spans {
+		fmt.Println("  " + history.FormatSpan(s))
+	}
+	fmt.Println("  " + history.Summary(spans))
+}
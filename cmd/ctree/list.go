@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gxespino/ctree/internal/daemon"
+	"github.com/gxespino/ctree/internal/history"
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/tracker"
+)
+
+// listEntry is one session in `ctree list` output.
+type listEntry struct {
+	Target         string    `json:"target"`
+	Session        string    `json:"session"`
+	Window         int       `json:"window"`
+	WindowName     string    `json:"window_name"`
+	PaneID         string    `json:"pane_id"`
+	Agent          string    `json:"agent"`
+	SessionID      string    `json:"session_id,omitempty"`
+	Status         string    `json:"status"`
+	StatusInferred bool      `json:"status_inferred,omitempty"`
+	Request        string    `json:"request,omitempty"`
+	CWD            string    `json:"cwd"`
+	Branch         string    `json:"branch,omitempty"`
+	Added          int       `json:"added"`
+	Removed        int       `json:"removed"`
+	Dirty          bool      `json:"dirty"`
+	LastActivity   time.Time `json:"last_activity"`
+	PID            int       `json:"pid"`
+}
+
+// runList prints every agent session for scripts.
+// Usage: ctree list [--json | --tsv]
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print a JSON array")
+	asTSV := fs.Bool("tsv", false, "print tab-separated values with a header row")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	windows, err := listSessions()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]listEntry, 0, len(windows))
+	for _, w := range windows {
+		entries = append(entries, newListEntry(w))
+	}
+
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case *asTSV:
+		printListTSV(entries)
+	default:
+		printListTable(entries)
+	}
+	return nil
+}
+
+// listSessions asks the daemon, whose statuses include Unread and Done,
+// and otherwise detects sessions directly. tmux commands reach the
+// default server when run outside $TMUX.
+func listSessions() ([]model.Window, error) {
+	if windows, err := daemon.Snapshot(); err == nil {
+		return windows, nil
+	}
+
+	windows, err := tracker.Detect()
+	if err != nil {
+		return nil, err
+	}
+	tracker.FillGit(windows)
+	tracker.Sort(windows)
+	return windows, nil
+}
+
+func newListEntry(w model.Window) listEntry {
+	e := listEntry{
+		Target:         w.Target(),
+		Session:        w.SessionName,
+		Window:         w.WindowIndex,
+		WindowName:     w.WindowName,
+		PaneID:         w.PaneID,
+		Agent:          w.Agent,
+		SessionID:      w.SessionID,
+		Status:         history.StatusName(w.Status),
+		StatusInferred: w.StatusInferred,
+		CWD:            w.WorkingDir,
+		Branch:         w.GitBranch,
+		Added:          w.GitAdded,
+		Removed:        w.GitRemoved,
+		Dirty:          w.GitDirty,
+		LastActivity:   w.LastActivity,
+		PID:            w.ClaudePID,
+	}
+	if w.Status == model.StatusPaused || w.Status == model.StatusWorking {
+		e.Request = w.Request()
+	}
+	return e
+}
+
+// printListTSV prints one line per session. Tabs and newlines in values
+// are replaced with spaces so columns stay aligned for cut and awk.
+func printListTSV(entries []listEntry) {
+	clean := strings.NewReplacer("\t", " ", "\n", " ")
+	fmt.Println("target\tpane_id\tagent\tstatus\tbranch\tadded\tremoved\tcwd\tlast_activity\trequest")
+	for _, e := range entries {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			clean.Replace(e.Target), e.PaneID, e.Agent, e.Status, clean.Replace(e.Branch),
+			e.Added, e.Removed, clean.Replace(e.CWD), e.LastActivity.Format(time.RFC3339),
+			clean.Replace(e.Request))
+	}
+}
+
+// printListTable prints an aligned table for people.
+func printListTable(entries []listEntry) {
+	if len(entries) == 0 {
+		fmt.Println("No agent sessions found.")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tPANE\tAGENT\tSTATUS\tBRANCH\tDIFF\tDIR")
+	for _, e := range entries {
+		diff := ""
+		if e.Added > 0 || e.Removed > 0 {
+			diff = fmt.Sprintf("+%d -%d", e.Added, e.Removed)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Target, e.PaneID, e.Agent, e.Status, e.Branch, diff, e.CWD)
+	}
+	tw.Flush()
+}
@@ -2,20 +2,23 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gxespino/ctree/internal/agent"
+	"github.com/gxespino/ctree/internal/daemon"
 	"github.com/gxespino/ctree/internal/hook"
-	"github.com/gxespino/ctree/internal/setup"
 	"github.com/gxespino/ctree/internal/slack"
-	"github.com/gxespino/ctree/internal/state"
 	"github.com/gxespino/ctree/internal/ui"
 )
 
 func main() {
+	var opts ui.Options
+
 	// Subcommand dispatch — these do not require tmux
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -31,16 +34,78 @@ func main() {
 			return
 		case "setup":
 			force := len(os.Args) > 2 && os.Args[2] == "--force"
-			if !force && setup.Check() {
-				fmt.Println("ctree hooks already configured in ~/.claude/settings.json")
-				return
+			for _, a := range agent.All() {
+				hooks := a.Hooks()
+				if hooks == nil {
+					continue
+				}
+				if !force && hooks.Installed() {
+					fmt.Printf("ctree hooks already configured in %s\n", hooks.Path())
+					continue
+				}
+				if err := hooks.Install(); err != nil {
+					fmt.Fprintf(os.Stderr, "ctree setup: %s: %v\n", a.DisplayName(), err)
+					os.Exit(1)
+				}
+				fmt.Printf("ctree hooks configured in %s\n", hooks.Path())
+			}
+			return
+		case "daemon":
+			if err := daemon.Serve(); err != nil {
+				if errors.Is(err, daemon.ErrRunning) {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "ctree daemon: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "history":
+			if err := runHistory(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ctree history: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "list":
+			if err := runList(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ctree list: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "new":
+			if err := runNew(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ctree new: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "send":
+			if err := runSend(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ctree send: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "status":
+			if err := runStatus(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ctree status: %v\n", err)
+				os.Exit(1)
 			}
-			if err := setup.Run(); err != nil {
-				fmt.Fprintf(os.Stderr, "ctree setup: %v\n", err)
+			return
+		case "sidebar":
+			if err := runSidebar(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ctree sidebar: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Println("ctree hooks configured in ~/.claude/settings.json")
 			return
+		case "popup":
+			inside, err := runPopup(os.Args[2:])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ctree popup: %v\n", err)
+				os.Exit(1)
+			}
+			if !inside {
+				return
+			}
+			// Running inside the popup: the TUI, quitting after a jump
+			opts.OneShot = true
 		case "slack-setup":
 			if err := runSlackSetup(); err != nil {
 				fmt.Fprintf(os.Stderr, "ctree slack-setup: %v\n", err)
@@ -61,23 +126,15 @@ func main() {
 		os.Exit(1)
 	}
 
-	persistedState, err := state.Load()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ctree: failed to load state: %v\n", err)
-		os.Exit(1)
-	}
-
-	app := ui.NewApp(persistedState)
+	app := ui.NewApp(opts)
 	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithReportFocus())
 
+	// State is saved as it changes, by the daemon or (without one) the app.
+	// Saving here could clobber the daemon's newer state.
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "ctree: %v\n", err)
 		os.Exit(1)
 	}
-
-	if err := state.Save(persistedState); err != nil {
-		fmt.Fprintf(os.Stderr, "ctree: failed to save state: %v\n", err)
-	}
 }
 
 func runSlackSetup() error {
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gxespino/ctree/internal/agent"
+	"github.com/gxespino/ctree/internal/tmux"
+)
+
+// runNew starts an agent in a new background tmux window and prints its
+// pane ID, for Makefiles and git hooks.
+// Usage: ctree new [--name NAME] [--dir DIR] [--session S]
+//
+//	[--prompt TEXT | --prompt-file F] [--model M] [-- AGENT ARGS...]
+func runNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	name := fs.String("name", "", "window name (default: tmux names it after the agent)")
+	dir := fs.String("dir", "", "working directory (default: the current directory)")
+	session := fs.String("session", "", "tmux session to open the window in (default: the current or most recent one)")
+	prompt := fs.String("prompt", "", "first message for the agent")
+	promptFile := fs.String("prompt-file", "", "read the first message from a file, or - for stdin")
+	modelName := fs.String("model", "", "model for the agent to use")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *prompt != "" && *promptFile != "" {
+		return errors.New("--prompt and --prompt-file are mutually exclusive")
+	}
+	text := *prompt
+	if *promptFile != "" {
+		var err error
+		if text, err = readPrompt(*promptFile); err != nil {
+			return err
+		}
+	}
+
+	// tmux would otherwise start in the session's directory, not ours
+	workDir := *dir
+	if workDir == "" {
+		workDir = "."
+	}
+	workDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return err
+	}
+
+	argv, err := agent.Default().LaunchCommand(agent.Launch{
+		Prompt: text,
+		Model:  *modelName,
+		Args:   fs.Args(),
+	})
+	if err != nil {
+		return err
+	}
+
+	paneID, err := tmux.NewAgentWindow(tmux.WindowOptions{
+		Name:     *name,
+		Dir:      workDir,
+		Session:  *session,
+		Detached: true,
+	}, argv)
+	if err != nil {
+		return err
+	}
+	fmt.Println(paneID)
+	return nil
+}
+
+// readPrompt reads a prompt file, or stdin for "-".
+func readPrompt(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/gxespino/ctree/internal/tmux"
+)
+
+// runPopup opens the TUI in a tmux popup as a transient session switcher.
+// Usage: ctree popup [--width 80%] [--height 80%]
+//
+// The popup re-runs `ctree popup --inside`, for which runPopup returns
+// inside=true so main starts the TUI in one-shot mode.
+func runPopup(args []string) (inside bool, err error) {
+	fs := flag.NewFlagSet("popup", flag.ContinueOnError)
+	width := fs.String("width", "80%", "popup width, in columns or percent")
+	height := fs.String("height", "80%", "popup height, in rows or percent")
+	insideFlag := fs.Bool("inside", false, "run the TUI (used by the popup itself)")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if *insideFlag {
+		return true, nil
+	}
+
+	if os.Getenv("TMUX") == "" {
+		return false, errors.New("must be run inside a tmux session")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	return false, tmux.DisplayPopup(shellQuote(exe)+" popup --inside", *width, *height)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gxespino/ctree/internal/history"
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/tmux"
+)
+
+// runSend types a follow-up prompt into an agent session and submits it.
+// Usage: ctree send <target> <text...>
+//
+// target is a pane ID (%12), a tmux target (work:3 or work:3.1) or a
+// prefix of the Claude session ID. The session must be at its prompt.
+func runSend(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: ctree send <target> <text>")
+	}
+	text := strings.Join(args[1:], " ")
+	if strings.TrimSpace(text) == "" {
+		return errors.New("nothing to send")
+	}
+
+	windows, err := listSessions()
+	if err != nil {
+		return err
+	}
+	w, err := findSession(windows, args[0])
+	if err != nil {
+		return err
+	}
+	if !w.AcceptsInput() {
+		return fmt.Errorf("%s is %s; prompts can only be sent to idle, unread or done sessions",
+			args[0], history.StatusName(w.Status))
+	}
+	return tmux.SendText(w.PaneID, text)
+}
+
+// findSession resolves a target to exactly one agent session.
+func findSession(windows []model.Window, target string) (model.Window, error) {
+	var matches []model.Window
+	for _, w := range windows {
+		pane := fmt.Sprintf("%s.%d", w.Target(), w.PaneIndex)
+		if w.PaneID == target || w.Target() == target || pane == target ||
+			(w.SessionID != "" && target != "" && strings.HasPrefix(w.SessionID, target)) {
+			matches = append(matches, w)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return model.Window{}, fmt.Errorf("no agent session matches %q", target)
+	case 1:
+		return matches[0], nil
+	default:
+		return model.Window{}, fmt.Errorf("%q matches %d sessions; use a pane ID", target, len(matches))
+	}
+}
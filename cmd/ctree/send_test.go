@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gxespino/ctree/internal/model"
+)
+
+func TestFindSession(t *testing.T) {
+	windows := []model.Window{
+		{PaneID: "%1", SessionName: "main", WindowIndex: 0, PaneIndex: 0, SessionID: "abc123"},
+		{PaneID: "%2", SessionName: "main", WindowIndex: 1, PaneIndex: 0, SessionID: "abd456"},
+		{PaneID: "%3", SessionName: "main", WindowIndex: 1, PaneIndex: 1, SessionID: "f00"},
+		{PaneID: "%4", SessionName: "work", WindowIndex: 2, PaneIndex: 0},
+	}
+
+	tests := []struct {
+		target string
+		want   string // pane ID, "" for an error
+	}{
+		{"%1", "%1"},
+		{"main:0", "%1"},
+		{"main:1", ""}, // two panes in the window
+		{"main:1.1", "%3"},
+		{"work:2", "%4"},
+		{"abc", "%1"},
+		{"ab", ""}, // prefix of two session IDs
+		{"abd456", "%2"},
+		{"f00", "%3"},
+		{"zzz", ""},
+		{"", ""}, // a prefix of every session ID
+	}
+	for _, tt := range tests {
+		got, err := findSession(windows, tt.target)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("findSession(%q) = %s, want an error", tt.target, got.PaneID)
+			}
+			continue
+		}
+		if err != nil || got.PaneID != tt.want {
+			t.Errorf("findSession(%q) = %s, %v; want %s", tt.target, got.PaneID, err, tt.want)
+		}
+	}
+}
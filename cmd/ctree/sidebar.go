@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gxespino/ctree/internal/config"
+	"github.com/gxespino/ctree/internal/tmux"
+)
+
+// runSidebar opens, closes or reports ctree sidebars.
+// Usage: ctree sidebar toggle|open|close|status [--scope global|session]
+// [--width N] [--side left|right|top|bottom] [--position window|pane]
+func runSidebar(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ctree sidebar toggle|open|close|status [flags]")
+	}
+	action := args[0]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.Path(), err)
+	}
+	sc := cfg.Sidebar
+
+	fs := flag.NewFlagSet("sidebar "+action, flag.ContinueOnError)
+	fs.IntVar(&sc.Width, "width", sc.Width, "columns (rows for top/bottom)")
+	fs.StringVar(&sc.Side, "side", sc.Side, "left, right, top or bottom")
+	fs.StringVar(&sc.Position, "position", sc.Position, "window (span the window edge) or pane (split the active pane)")
+	fs.StringVar(&sc.Scope, "scope", sc.Scope, "global (every session) or session (the current one)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	opts, err := sidebarOptions(sc)
+	if err != nil {
+		return err
+	}
+
+	if action != "status" {
+		unlock, err := lockSidebars()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	// auto-open runs from the after-new-window hook with the new window
+	if action == "auto-open" {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: ctree sidebar auto-open WINDOW_ID")
+		}
+		// The hook outlives sidebars closed by hand; only follow open ones
+		open, err := tmux.ListSidebars("")
+		if err != nil || len(open) == 0 {
+			return err
+		}
+		return tmux.OpenSidebar(fs.Arg(0), opts)
+	}
+
+	var sessionID string
+	switch sc.Scope {
+	case "global":
+	case "session":
+		if sessionID, err = tmux.CurrentSession(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("scope must be global or session, not %q", sc.Scope)
+	}
+
+	switch action {
+	case "open":
+		return tmux.OpenSidebars(sessionID, opts)
+	case "close":
+		return tmux.CloseSidebars(sessionID)
+	case "toggle":
+		open, err := tmux.ListSidebars(sessionID)
+		if err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			return tmux.CloseSidebars(sessionID)
+		}
+		return tmux.OpenSidebars(sessionID, opts)
+	case "status":
+		open, err := tmux.ListSidebars(sessionID)
+		if err != nil {
+			return err
+		}
+		if len(open) == 0 {
+			fmt.Println("closed")
+		} else {
+			fmt.Printf("open in %d window(s)\n", len(open))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q (want toggle, open, close or status)", action)
+	}
+}
+
+// sidebarOptions builds the tmux options for sc. New windows get a
+// sidebar through a hook that re-runs this binary with the same settings.
+func sidebarOptions(sc config.Sidebar) (tmux.SidebarOptions, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return tmux.SidebarOptions{}, err
+	}
+
+	opts := tmux.SidebarOptions{
+		Width:    sc.Width,
+		Side:     sc.Side,
+		Position: sc.Position,
+		Command:  "exec " + shellQuote(exe),
+	}
+	if err := tmux.ValidateSidebarOptions(opts); err != nil {
+		return opts, err
+	}
+
+	opts.AutoOpen = strings.Join([]string{
+		shellQuote(exe), "sidebar", "auto-open",
+		"--width", strconv.Itoa(sc.Width),
+		"--side", sc.Side,
+		"--position", sc.Position,
+		"'#{window_id}'",
+	}, " ")
+	return opts, nil
+}
+
+// lockSidebars serializes sidebar changes between ctree processes. A
+// sidebar is tagged just after its pane is split off, so a toggle and the
+// auto-open hook of a new window could otherwise both find the window
+// without one and each open a sidebar. The lock is released when the
+// process exits, however it does.
+func lockSidebars() (unlock func(), err error) {
+	dir := filepath.Dir(config.Path())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "sidebar.lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
+
+// shellQuote single-quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gxespino/ctree/internal/daemon"
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/tracker"
+)
+
+// statusGlyphs is the compact status-line form: one colored glyph and
+// count per status that has sessions, matching the sidebar's colors.
+var statusGlyphs = []struct {
+	status model.Status
+	glyph  string
+	color  string
+}{
+	{model.StatusPaused, "▶", "#F97316"},
+	{model.StatusWorking, "●", "#F59E0B"},
+	{model.StatusUnread, "◆", "#3B82F6"},
+	{model.StatusDone, "✓", "#10B981"},
+}
+
+// runStatus prints aggregate session counts for a tmux status line.
+// Usage: ctree status [--format '{working} {paused} {unread}']
+//
+// It runs every few seconds from status-right, so it never runs full
+// detection: counts come from the daemon's last snapshot, or from the hook
+// status files when no daemon is running.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	format := fs.String("format", "", "template with {working} {paused} {idle} {unread} {done} {total}; default is compact glyphs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	counts := statusCounts()
+	if *format == "" {
+		fmt.Println(glyphStatus(counts))
+		return nil
+	}
+	fmt.Println(expandStatus(*format, counts))
+	return nil
+}
+
+// statusCounts counts sessions by status.
+func statusCounts() map[model.Status]int {
+	counts := make(map[model.Status]int)
+
+	if windows, err := daemon.Snapshot(); err == nil {
+		for _, w := range windows {
+			counts[w.Status]++
+		}
+		return counts
+	}
+
+	// No daemon: detect the panes still running an agent. Without the
+	// state machine there is no Unread or Done.
+	windows, err := tracker.Detect()
+	if err != nil {
+		return counts
+	}
+	for _, w := range windows {
+		if w.Status != model.StatusExited {
+			counts[w.Status]++
+		}
+	}
+	return counts
+}
+
+// expandStatus fills in a --format template.
+func expandStatus(format string, counts map[model.Status]int) string {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	n := func(s model.Status) string { return strconv.Itoa(counts[s]) }
+	return strings.NewReplacer(
+		"{working}", n(model.StatusWorking),
+		"{paused}", n(model.StatusPaused),
+		"{idle}", n(model.StatusIdle),
+		"{unread}", n(model.StatusUnread),
+		"{done}", n(model.StatusDone),
+		"{total}", strconv.Itoa(total),
+	).Replace(format)
+}
+
+// glyphStatus renders counts compactly with tmux color codes, e.g.
+// "▶1 ●2 ◆1". Statuses with no sessions are left out; with nothing to
+// show the segment is empty.
+func glyphStatus(counts map[model.Status]int) string {
+	var parts []string
+	for _, g := range statusGlyphs {
+		if n := counts[g.status]; n > 0 {
+			parts = append(parts, fmt.Sprintf("#[fg=%s]%s%d", g.color, g.glyph, n))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " ") + "#[default]"
+}
@@ -0,0 +1,187 @@
+// Package agent adapts coding agent CLIs (Claude Code, Codex CLI, Aider,
+// Gemini CLI) to ctree: how to recognize one in the process tree or by its
+// pane, how to launch it, and where its status comes from.
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// StatusSource says how ctree learns an agent's status.
+type StatusSource int
+
+const (
+	// StatusFromHooks means the agent reports status through `ctree hook`.
+	StatusFromHooks StatusSource = iota
+
+	// StatusFromPane means status is inferred from the pane's content.
+	StatusFromPane
+)
+
+// Agent is one coding agent CLI.
+type Agent interface {
+	// Name is the short identifier stored in model.Window.Agent ("claude").
+	Name() string
+
+	// DisplayName is the product name shown to the user ("Claude Code").
+	DisplayName() string
+
+	// MatchProcess reports whether a process is this agent. cmdline reads
+	// the full command line; it is only worth calling for interpreters,
+	// since most processes are rejected by comm alone.
+	MatchProcess(comm string, cmdline func() string) bool
+
+	// MatchPane reports whether captured pane content looks like this
+	// agent's UI, for processes the command line can't identify.
+	MatchPane(content string) bool
+
+	// PaneStatus infers status from captured pane content for sessions
+	// without hook data: Working, Paused or Idle, or StatusUnknown if the
+	// content shows none of the agent's markers.
+	PaneStatus(content string) model.Status
+
+	// LaunchCommand is the argv that starts the agent in a new pane. It
+	// fails if the agent can't do what l asks for.
+	LaunchCommand(l Launch) ([]string, error)
+
+	// StatusSource says where this agent's status comes from.
+	StatusSource() StatusSource
+
+	// Hooks returns the agent's hook installer, or nil if it has none.
+	Hooks() HookInstaller
+}
+
+// Launch describes a new agent session.
+type Launch struct {
+	// Prompt, if set, is sent as the session's first message.
+	Prompt string
+
+	// Model, if set, selects the agent's model (--model).
+	Model string
+
+	// Args are extra command line arguments for the agent, passed as is.
+	Args []string
+
+	// Resume, if set, is the ID of an earlier session to continue.
+	Resume string
+}
+
+// HookInstaller configures an agent to report status through `ctree hook`.
+type HookInstaller interface {
+	// Installed reports whether ctree's hooks are already configured.
+	Installed() bool
+
+	// Install writes ctree's hooks, replacing any older ctree entries.
+	Install() error
+
+	// Path is the settings file the hooks live in, for messages.
+	Path() string
+}
+
+// All returns the built-in adapters in detection order.
+func All() []Agent {
+	return builtins
+}
+
+// ByName returns the adapter with the given Name, or nil.
+func ByName(name string) Agent {
+	for _, a := range builtins {
+		if a.Name() == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Default returns the agent new windows launch: $CTREE_AGENT if it names
+// a known adapter, else Claude Code.
+func Default() Agent {
+	if a := ByName(os.Getenv("CTREE_AGENT")); a != nil {
+		return a
+	}
+	return Claude
+}
+
+// interpreters run agents shipped as scripts, so their comm is the
+// interpreter's rather than the agent's.
+var interpreters = []string{"node", "bun", "deno", "python", "python3"}
+
+// IsInterpreter reports whether comm is a script interpreter that might be
+// running an agent.
+func IsInterpreter(comm string) bool {
+	for _, name := range interpreters {
+		if comm == name {
+			return true
+		}
+	}
+	// Versioned pythons: python3.12
+	return strings.HasPrefix(comm, "python3.")
+}
+
+// matchCommand reports whether a process runs one of names, either
+// directly (comm) or as a script under an interpreter
+// ("node /usr/local/bin/codex", "python -m aider").
+func matchCommand(comm string, cmdline func() string, names []string) bool {
+	if contains(names, comm) {
+		return true
+	}
+	if !IsInterpreter(comm) {
+		return false
+	}
+
+	args := strings.Fields(cmdline())
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-m" && i+1 < len(args) {
+			return contains(names, args[i+1])
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		// The first non-flag argument is the script
+		script := filepath.Base(arg)
+		script = strings.TrimSuffix(script, filepath.Ext(script))
+		return contains(names, script)
+	}
+	return false
+}
+
+// statusLines is how much of the bottom of a pane PaneStatus looks at.
+// Agents draw their spinner, dialogs and input box there; older output
+// above may quote the markers.
+const statusLines = 20
+
+// bottomLines returns the last n non-blank lines of content.
+func bottomLines(content string, n int) string {
+	lines := strings.Split(content, "\n")
+	var kept []string
+	for i := len(lines) - 1; i >= 0 && len(kept) < n; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			kept = append(kept, lines[i])
+		}
+	}
+	// Order doesn't matter for marker matching
+	return strings.Join(kept, "\n")
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
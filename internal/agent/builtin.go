@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/setup"
+)
+
+// Built-in adapters.
+var (
+	Claude Agent = &cli{
+		name:     "claude",
+		display:  "Claude Code",
+		commands: []string{"claude"},
+		markers:  []string{"? for shortcuts", "Claude Code"},
+		working:  []string{"esc to interrupt"},
+		paused:   []string{"Do you want to proceed?", "Do you want to make this edit", "Do you want to create", "❯ 1. Yes"},
+		idle:     []string{"? for shortcuts", "│ > ", "\n> "},
+		launch:   []string{"claude"},
+		resume:   "--resume",
+		source:   StatusFromHooks,
+		hooks:    claudeHooks{},
+	}
+
+	Codex Agent = &cli{
+		name:     "codex",
+		display:  "Codex CLI",
+		commands: []string{"codex"},
+		markers:  []string{"OpenAI Codex"},
+		working:  []string{"esc to interrupt"},
+		paused:   []string{"Allow command?", "Yes (y)", "Proceed with"},
+		idle:     []string{"⏎ send", "send message"},
+		launch:   []string{"codex"},
+		source:   StatusFromPane,
+	}
+
+	Aider Agent = &cli{
+		name:     "aider",
+		display:  "Aider",
+		commands: []string{"aider"},
+		markers:  []string{"Aider v"},
+		paused:   []string{"(Y)es/(N)o"},
+		idle:     []string{"\n> "},
+		launch:   []string{"aider"},
+		noPrompt: true, // --message answers once and exits
+		source:   StatusFromPane,
+	}
+
+	Gemini Agent = &cli{
+		name:       "gemini",
+		display:    "Gemini CLI",
+		commands:   []string{"gemini"},
+		markers:    []string{"Gemini CLI", "gemini-"},
+		working:    []string{"esc to cancel"},
+		paused:     []string{"Allow execution", "Apply this change?"},
+		idle:       []string{"Type your message"},
+		launch:     []string{"gemini"},
+		promptFlag: "--prompt-interactive",
+		source:     StatusFromPane,
+	}
+)
+
+var builtins = []Agent{Claude, Codex, Aider, Gemini}
+
+// cli is an Agent described by data: the command names it runs as, text
+// its UI always shows, and how to start it.
+type cli struct {
+	name     string
+	display  string
+	commands []string // process or script names
+	markers  []string // pane content unique to the agent's UI
+
+	// Pane content shown while working (spinner hint), waiting on a
+	// dialog, or waiting at the input box.
+	working []string
+	paused  []string
+	idle    []string
+
+	launch     []string
+	promptFlag string // flag for an initial prompt; "" passes it after "--"
+	noPrompt   bool   // the CLI can't start a chat with a prompt
+	resume     string // flag before a session ID to resume; "" if it can't
+
+	source StatusSource
+	hooks  HookInstaller
+}
+
+func (c *cli) Name() string               { return c.name }
+func (c *cli) DisplayName() string        { return c.display }
+func (c *cli) StatusSource() StatusSource { return c.source }
+func (c *cli) Hooks() HookInstaller       { return c.hooks }
+
+func (c *cli) LaunchCommand(l Launch) ([]string, error) {
+	argv := append([]string(nil), c.launch...)
+	if l.Resume != "" {
+		if c.resume == "" {
+			return nil, fmt.Errorf("%s can't resume a session", c.display)
+		}
+		argv = append(argv, c.resume, l.Resume)
+	}
+	if l.Model != "" {
+		argv = append(argv, "--model", l.Model)
+	}
+	argv = append(argv, l.Args...)
+	if l.Prompt != "" {
+		if c.noPrompt {
+			return nil, fmt.Errorf("%s can't start with a prompt", c.display)
+		}
+		// Keep a prompt starting with "-" from being parsed as a flag, or
+		// taken as another value of a variadic flag in Args
+		if c.promptFlag != "" {
+			argv = append(argv, c.promptFlag+"="+l.Prompt)
+		} else {
+			argv = append(argv, "--", l.Prompt)
+		}
+	}
+	return argv, nil
+}
+
+func (c *cli) MatchProcess(comm string, cmdline func() string) bool {
+	return matchCommand(comm, cmdline, c.commands)
+}
+
+func (c *cli) MatchPane(content string) bool {
+	return containsAny(content, c.markers)
+}
+
+// PaneStatus checks dialogs first: a permission dialog replaces the
+// input box, and the spinner line can linger above it.
+func (c *cli) PaneStatus(content string) model.Status {
+	// Leading newline so "\n> " matches a prompt on the first kept line
+	bottom := "\n" + bottomLines(content, statusLines)
+	switch {
+	case containsAny(bottom, c.paused):
+		return model.StatusPaused
+	case containsAny(bottom, c.working):
+		return model.StatusWorking
+	case containsAny(bottom, c.idle):
+		return model.StatusIdle
+	default:
+		return model.StatusUnknown
+	}
+}
+
+// claudeHooks installs ctree's hooks into Claude Code's settings.
+type claudeHooks struct{}
+
+func (claudeHooks) Installed() bool { return setup.Check() }
+func (claudeHooks) Install() error  { return setup.Run() }
+func (claudeHooks) Path() string    { return "~/.claude/settings.json" }
@@ -0,0 +1,100 @@
+// Package config reads user settings from ~/.config/ctree/config.json.
+// ctree is zero-config: the file is optional, and so is every field.
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds user settings.
+type Config struct {
+	Sidebar   Sidebar   `json:"sidebar"`
+	Worktrees Worktrees `json:"worktrees"`
+}
+
+// Sidebar configures `ctree sidebar`.
+type Sidebar struct {
+	// Width is the sidebar's size in columns (rows when Side is top or
+	// bottom). $CTREE_SIDEBAR_WIDTH overrides it.
+	Width int `json:"width,omitempty"`
+
+	// Side is where the sidebar goes: left, right, top or bottom.
+	Side string `json:"side,omitempty"`
+
+	// Position is "window" to span the whole window edge, or "pane" to
+	// split only the window's active pane.
+	Position string `json:"position,omitempty"`
+
+	// Scope is "global" to open sidebars in every window of every
+	// session, or "session" for the current session's windows only.
+	Scope string `json:"scope,omitempty"`
+}
+
+// Worktrees configures the git worktrees new agents get from the sidebar.
+type Worktrees struct {
+	// Root is the directory worktrees are created under, as
+	// <root>/<repo>/<branch>. A leading ~ is the home directory.
+	Root string `json:"root,omitempty"`
+}
+
+// Path returns the config file path (~/.config/ctree/config.json).
+func Path() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "ctree", "config.json")
+}
+
+// Load reads the config file, filling in defaults for anything unset.
+// A missing file is not an error.
+func Load() (*Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(Path())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if w, err := strconv.Atoi(os.Getenv("CTREE_SIDEBAR_WIDTH")); err == nil && w > 0 {
+		cfg.Sidebar.Width = w
+	}
+	cfg.applyDefaults()
+	return &cfg, nil
+}
+
+func (c *Config) applyDefaults() {
+	if c.Sidebar.Width <= 0 {
+		c.Sidebar.Width = 40
+	}
+	if c.Sidebar.Side == "" {
+		c.Sidebar.Side = "left"
+	}
+	if c.Sidebar.Position == "" {
+		c.Sidebar.Position = "window"
+	}
+	if c.Sidebar.Scope == "" {
+		c.Sidebar.Scope = "global"
+	}
+	if c.Worktrees.Root == "" {
+		c.Worktrees.Root = "~/.local/share/ctree/worktrees"
+	}
+	c.Worktrees.Root = ExpandHome(c.Worktrees.Root)
+}
+
+// ExpandHome replaces a leading ~ in path with the home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
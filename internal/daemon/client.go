@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+const (
+	// dialTimeout bounds connecting to the socket. A hook must never stall
+	// Claude Code waiting on a wedged daemon.
+	dialTimeout = 100 * time.Millisecond
+
+	// ackTimeout bounds waiting for the daemon to apply a hook update or
+	// answer a snapshot request.
+	ackTimeout = 500 * time.Millisecond
+
+	// startTimeout is how long Start waits for a spawned daemon to listen.
+	startTimeout = time.Second
+)
+
+// Running reports whether a daemon is accepting connections.
+func Running() bool {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Start spawns a detached `ctree daemon` if none is running, and waits
+// briefly for it to come up.
+func Start() error {
+	if Running() {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "daemon")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	_ = cmd.Process.Release()
+
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		if Running() {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return errors.New("daemon did not start")
+}
+
+// Publish sends a hook status to the daemon, which writes it and notifies
+// every subscribed sidebar. Returns an error if no daemon applied it, in
+// which case the caller should write the status file itself.
+func Publish(status hookdata.HookStatus) error {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(ackTimeout))
+
+	if err := json.NewEncoder(conn).Encode(Message{Type: TypeHook, Status: &status}); err != nil {
+		return err
+	}
+
+	var reply Message
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return err
+	}
+	if reply.Type != TypeAck {
+		return errors.New("unexpected reply from daemon")
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return nil
+}
+
+// Snapshot fetches the daemon's current session list, with the same
+// refined statuses (Unread, Done) and git stats the sidebars show.
+// Unlike Subscribe, it doesn't count as a sidebar, so polling it from a
+// status line doesn't keep an otherwise idle daemon alive.
+func Snapshot() ([]model.Window, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(ackTimeout))
+
+	if err := json.NewEncoder(conn).Encode(Message{Type: TypeList}); err != nil {
+		return nil, err
+	}
+
+	var reply Message
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return nil, err
+	}
+	if reply.Type != TypeSnapshot {
+		return nil, errors.New("unexpected reply from daemon")
+	}
+	if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+	return reply.Windows, nil
+}
+
+// Subscription is a sidebar's stream of snapshots from the daemon.
+type Subscription struct {
+	conn net.Conn
+	dec  *json.Decoder
+}
+
+// Subscribe connects to the daemon and asks for snapshots.
+func Subscribe() (*Subscription, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(Message{Type: TypeSubscribe}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Subscription{conn: conn, dec: json.NewDecoder(conn)}, nil
+}
+
+// Next blocks until the daemon sends a message. An error means the
+// daemon went away.
+func (s *Subscription) Next() (Message, error) {
+	var msg Message
+	err := s.dec.Decode(&msg)
+	return msg, err
+}
+
+// MarkSeen tells the daemon the user jumped to this agent pane.
+func (s *Subscription) MarkSeen(paneID string) error {
+	return json.NewEncoder(s.conn).Encode(Message{Type: TypeSeen, PaneID: paneID})
+}
+
+// Refresh asks the daemon to poll now.
+func (s *Subscription) Refresh() error {
+	return json.NewEncoder(s.conn).Encode(Message{Type: TypeRefresh})
+}
+
+// Close disconnects from the daemon.
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
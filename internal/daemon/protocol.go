@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// Message types exchanged over the socket. Each message is one line of JSON.
+const (
+	TypeHook      = "hook"      // hook → daemon: apply a status update
+	TypeAck       = "ack"       // daemon → hook: update applied
+	TypeSubscribe = "subscribe" // sidebar → daemon: stream snapshots on this connection
+	TypeSnapshot  = "snapshot"  // daemon → sidebar: the current session list
+	TypeSeen      = "seen"      // sidebar → daemon: user jumped to PaneID
+	TypeRefresh   = "refresh"   // sidebar → daemon: poll now
+	TypeList      = "list"      // client → daemon: reply with one snapshot, without subscribing
+)
+
+// Message is one newline-delimited JSON message on the ctree socket.
+type Message struct {
+	Type   string               `json:"type"`
+	Status *hookdata.HookStatus `json:"status,omitempty"`
+	Error  string               `json:"error,omitempty"`
+
+	// Windows and Chime carry a snapshot: every Claude session with
+	// refined status and git stats, and whether one just needs attention.
+	Windows []model.Window `json:"windows,omitempty"`
+	Chime   bool           `json:"chime,omitempty"`
+
+	// Exited lists recently ended sessions in a snapshot, for resuming.
+	Exited []model.ExitedSession `json:"exited,omitempty"`
+
+	// PaneID is the agent pane the user jumped to, for TypeSeen.
+	PaneID string `json:"pane_id,omitempty"`
+}
+
+// SocketPath returns the daemon socket path (~/.config/ctree/ctree.sock).
+func SocketPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "ctree", "ctree.sock")
+}
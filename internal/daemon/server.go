@@ -0,0 +1,359 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gxespino/ctree/internal/detect"
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/state"
+	"github.com/gxespino/ctree/internal/tmux"
+	"github.com/gxespino/ctree/internal/tracker"
+)
+
+const (
+	// idleTimeout is how long the daemon lingers with no subscribed sidebars
+	// before exiting. Hooks fall back to the file drop once it is gone.
+	idleTimeout = 10 * time.Minute
+
+	// pollInterval is the periodic poll. Hook updates and tmux control-mode
+	// events trigger an immediate poll, so this only catches agents
+	// starting or exiting, activity and Done decay.
+	pollInterval = time.Second
+
+	// controlRetryPolls is how many polls to wait between attempts to
+	// (re)attach the tmux control client, e.g. after the tmux server
+	// restarted. Until then panes are listed with list-panes.
+	controlRetryPolls = 10
+)
+
+// ErrRunning is returned by Serve when another daemon already owns the socket.
+var ErrRunning = errors.New("daemon already running")
+
+// server owns detection and the Unread/Done state machine for every
+// sidebar, and pushes snapshots to them.
+type server struct {
+	mu       sync.Mutex
+	subs     map[chan Message]struct{}
+	lastIdle time.Time      // when the last subscriber left
+	snapshot []model.Window // last snapshot, sent to new subscribers
+	polled   bool           // snapshot is valid, even if empty
+
+	exited []model.ExitedSession // recently ended sessions, sent with the snapshot
+
+	wake chan struct{} // poll now
+	seen chan string   // panes the user jumped to
+}
+
+// Serve listens on SocketPath() until SIGINT/SIGTERM, or until no sidebar
+// has been subscribed for idleTimeout.
+func Serve() error {
+	path := SocketPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// The lock, not the socket, decides who runs: two sidebars may spawn
+	// daemons at the same moment, and the loser must not unlink the
+	// winner's socket.
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return ErrRunning
+	}
+
+	persisted, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	os.Remove(path) // stale socket from a daemon that didn't shut down cleanly
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	_ = os.Chmod(path, 0o600)
+
+	s := &server{
+		subs:     make(map[chan Message]struct{}),
+		lastIdle: time.Now(),
+		wake:     make(chan struct{}, 1),
+		seen:     make(chan string, 16),
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ln.Close()
+	}()
+	go s.exitWhenIdle(ln)
+	_ = tracker.MigrateState(persisted)
+	go s.run(tracker.New(persisted))
+	s.poke()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// run is the poll loop: detect, refine, attach git stats, publish.
+// It is the only goroutine that touches the tracker and state.json.
+func (s *server) run(t *tracker.Tracker) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// A tmux control client keeps the pane table current and wakes us on
+	// window and focus changes, so a focused Unread session turns Done at
+	// once and no list-panes runs per poll.
+	var ctl *tmux.Control
+
+	// CPU and memory are only measured while sidebars show them
+	usage := &detect.Usage{}
+
+	for polls := 0; ; polls++ {
+		var ctlChanges, ctlDone <-chan struct{}
+		if ctl != nil {
+			ctlChanges, ctlDone = ctl.Changes(), ctl.Done()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-ctlChanges:
+		case <-ctlDone:
+			ctl = nil
+		case paneID := <-s.seen:
+			t.MarkSeen(paneID)
+		}
+
+		if ctl == nil && polls%controlRetryPolls == 0 {
+			ctl, _ = tmux.StartControl()
+		}
+
+		// Housekeeping: history logs (~1min)
+		if polls%60 == 0 {
+			hookdata.PruneHistory()
+		}
+
+		// Let PermissionRequest hooks know a sidebar can answer them
+		if s.subscribers() > 0 {
+			state.TouchSidebar()
+		}
+
+		panes, err := listPanes(ctl)
+		if err != nil {
+			continue
+		}
+
+		// Status files of closed panes (~15s)
+		if polls%15 == 0 {
+			tracker.CleanupHooks(panes)
+		}
+
+		var u *detect.Usage
+		if state.GetUsage() {
+			u = usage
+		}
+		windows := tracker.DetectPanes(panes, u)
+		res := t.Refine(windows)
+		_ = t.Save()
+		for _, ev := range res.Transitions {
+			_ = hookdata.AppendEvent(ev)
+		}
+
+		tracker.FillGit(windows)
+		tracker.Sort(windows)
+		s.publish(windows, t.Exited(), res.Chime)
+	}
+}
+
+// listPanes reads the control client's pane table, or runs list-panes if
+// there is no client or it hasn't received the table yet.
+func listPanes(ctl *tmux.Control) ([]model.Window, error) {
+	if ctl != nil {
+		if panes, ok := ctl.Panes(); ok {
+			return panes, nil
+		}
+	}
+	return tmux.ListAllPanes()
+}
+
+// publish broadcasts a snapshot if anything changed since the last one.
+// A chime is always delivered.
+func (s *server) publish(windows []model.Window, exited []model.ExitedSession, chime bool) {
+	s.mu.Lock()
+	unchanged := s.polled && reflect.DeepEqual(windows, s.snapshot) && reflect.DeepEqual(exited, s.exited)
+	if !unchanged {
+		s.snapshot = windows
+		s.exited = exited
+		s.polled = true
+	}
+	s.mu.Unlock()
+
+	if unchanged && !chime {
+		return
+	}
+	s.broadcast(Message{Type: TypeSnapshot, Windows: windows, Exited: exited, Chime: chime})
+}
+
+// poke requests an immediate poll without blocking.
+func (s *server) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// handle dispatches on a connection's first message.
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	var msg Message
+	if err := dec.Decode(&msg); err != nil {
+		return
+	}
+
+	switch msg.Type {
+	case TypeHook:
+		s.applyHook(conn, msg)
+	case TypeSubscribe:
+		s.serveSubscriber(conn, dec)
+	case TypeList:
+		s.mu.Lock()
+		reply := Message{Type: TypeSnapshot, Windows: s.snapshot}
+		if !s.polled {
+			// An empty list would read as "no sessions"; let the
+			// client detect them itself
+			reply.Error = "no snapshot yet"
+		}
+		s.mu.Unlock()
+		_ = json.NewEncoder(conn).Encode(reply)
+	}
+}
+
+// applyHook writes the status file, acks the hook, then polls.
+// The file is written before the ack so a hook that fires right after
+// (e.g., Stop after PermissionRequest) reads the update.
+func (s *server) applyHook(conn net.Conn, msg Message) {
+	if msg.Status == nil {
+		return
+	}
+
+	reply := Message{Type: TypeAck}
+	if err := hookdata.Write(*msg.Status); err != nil {
+		reply.Error = err.Error()
+	}
+	_ = json.NewEncoder(conn).Encode(reply)
+
+	s.poke()
+}
+
+// serveSubscriber streams snapshots to a sidebar and handles its
+// requests until it disconnects.
+func (s *server) serveSubscriber(conn net.Conn, dec *json.Decoder) {
+	// Capacity 1: a slow sidebar only ever needs the latest snapshot
+	ch := make(chan Message, 1)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	if s.polled {
+		ch <- Message{Type: TypeSnapshot, Windows: s.snapshot, Exited: s.exited}
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		if len(s.subs) == 0 {
+			s.lastIdle = time.Now()
+		}
+		s.mu.Unlock()
+	}()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			var req Message
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			switch req.Type {
+			case TypeSeen:
+				s.seen <- req.PaneID
+			case TypeRefresh:
+				s.poke()
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case msg := <-ch:
+			if err := enc.Encode(msg); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// broadcast hands a message to every subscriber, replacing any snapshot
+// it hasn't picked up yet. A replaced snapshot's chime carries over.
+func (s *server) broadcast(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		m := msg
+		select {
+		case old := <-ch:
+			m.Chime = m.Chime || old.Chime
+		default:
+		}
+		ch <- m
+	}
+}
+
+// subscribers returns how many sidebars are connected.
+func (s *server) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+// exitWhenIdle closes the listener once no sidebar has been subscribed for idleTimeout.
+func (s *server) exitWhenIdle(ln net.Listener) {
+	for range time.Tick(time.Minute) {
+		s.mu.Lock()
+		idle := len(s.subs) == 0 && time.Since(s.lastIdle) > idleTimeout
+		s.mu.Unlock()
+		if idle {
+			ln.Close()
+			return
+		}
+	}
+}
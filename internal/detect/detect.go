@@ -1,106 +1,203 @@
 package detect
 
 import (
-	"os/exec"
-	"strconv"
-	"strings"
+	"time"
 
+	"github.com/gxespino/ctree/internal/agent"
 	"github.com/gxespino/ctree/internal/hookdata"
 	"github.com/gxespino/ctree/internal/model"
 )
 
-// processInfo holds parsed ps output for one process.
-type processInfo struct {
-	pid  int
-	ppid int
-	comm string
-}
-
-// buildProcessTable runs `ps` once and returns a map of PID→processInfo
-// and a map of PPID→[]child PIDs.
-func buildProcessTable() (map[int]processInfo, map[int][]int) {
-	out, err := exec.Command("ps", "-eo", "pid,ppid,comm").Output()
-	if err != nil {
-		return nil, nil
+// findAgent looks for a process an agent adapter recognizes: the pane's
+// own process (tmux runs a lone command without a shell) or any
+// descendant. Returns the PID and adapter, or 0 and nil if not found.
+func findAgent(panePID int, table *procTable) (int, agent.Agent) {
+	if a := matchAgent(panePID, table); a != nil {
+		return panePID, a
 	}
+	return findAgentDescendant(panePID, table)
+}
 
-	procs := make(map[int]processInfo)
-	children := make(map[int][]int)
-
-	for _, line := range strings.Split(string(out), "\n") {
-		fields := strings.Fields(line)
-		if len(fields) < 3 {
-			continue
+// findAgentDescendant walks the process tree from parentPID looking for
+// any descendant an agent adapter recognizes.
+func findAgentDescendant(parentPID int, table *procTable) (int, agent.Agent) {
+	for _, childPID := range table.children[parentPID] {
+		if a := matchAgent(childPID, table); a != nil {
+			return childPID, a
 		}
-		pid, err1 := strconv.Atoi(fields[0])
-		ppid, err2 := strconv.Atoi(fields[1])
-		if err1 != nil || err2 != nil {
-			continue
-		}
-		// comm may contain path, take the basename
-		comm := fields[2]
-		if idx := strings.LastIndex(comm, "/"); idx >= 0 {
-			comm = comm[idx+1:]
+		// Recurse (shell → node → claude)
+		if pid, a := findAgentDescendant(childPID, table); pid > 0 {
+			return pid, a
 		}
-		procs[pid] = processInfo{pid: pid, ppid: ppid, comm: comm}
-		children[ppid] = append(children[ppid], pid)
 	}
-
-	return procs, children
+	return 0, nil
 }
 
-// findClaudeDescendant walks the process tree from parentPID looking for
-// any descendant named "claude". Returns the PID or 0 if not found.
-func findClaudeDescendant(parentPID int, procs map[int]processInfo, children map[int][]int) int {
-	for _, childPID := range children[parentPID] {
-		info := procs[childPID]
-		if info.comm == "claude" {
-			return childPID
+// matchAgent returns the adapter that recognizes pid, or nil.
+func matchAgent(pid int, table *procTable) agent.Agent {
+	info, ok := table.procs[pid]
+	if !ok {
+		return nil
+	}
+	cmdline := func() string { return table.cmdline(pid) }
+	for _, a := range agent.All() {
+		if a.MatchProcess(info.comm, cmdline) {
+			return a
 		}
-		// Recurse one more level (shell → node → claude)
-		found := findClaudeDescendant(childPID, procs, children)
-		if found > 0 {
-			return found
+	}
+	return nil
+}
+
+// findInterpreter returns the first interpreter (node, python, ...) at or
+// below panePID, which may be an agent its command line doesn't identify.
+func findInterpreter(panePID int, table *procTable) int {
+	if agent.IsInterpreter(table.procs[panePID].comm) {
+		return panePID
+	}
+	var walk func(parent int) int
+	walk = func(parent int) int {
+		for _, childPID := range table.children[parent] {
+			if agent.IsInterpreter(table.procs[childPID].comm) {
+				return childPID
+			}
+			if pid := walk(childPID); pid > 0 {
+				return pid
+			}
 		}
+		return 0
 	}
-	return 0
+	return walk(panePID)
 }
 
-// EnrichAll detects Claude status for all windows in a single pass.
+// EnrichAll detects agent status for all windows in a single pass.
 // Uses hook-based status files written by Claude Code lifecycle events.
-// Process liveness is always verified via the process table.
-func EnrichAll(windows []model.Window) {
-	procs, children := buildProcessTable()
-	if procs == nil {
+// Process liveness is always verified via the process table. CPU and
+// memory are filled in only if usage is non-nil.
+func EnrichAll(windows []model.Window, usage *Usage) {
+	table := buildProcessTable()
+	if table == nil {
 		return
 	}
 
 	hookStatuses := hookdata.ReadAll()
+	var prevCPU map[int]time.Duration
+	var elapsed time.Duration
+	if usage != nil {
+		prevCPU, elapsed = usage.sample(table)
+	}
 
 	for i := range windows {
 		w := &windows[i]
-		claudePID := findClaudeDescendant(w.PanePID, procs, children)
+		claudePID, a := findAgent(w.PanePID, table)
+		if claudePID == 0 {
+			pid := findInterpreter(w.PanePID, table)
+			claudePID, a = paneAgents.identify(w.PaneID, pid, table.procs[pid].startTime)
+		}
 		if claudePID == 0 {
 			w.IsClaudePane = false
 			w.Status = model.StatusExited
+			paneStatuses.forget(w.PaneID)
 			continue
 		}
 
 		w.IsClaudePane = true
 		w.ClaudePID = claudePID
+		w.Agent = a.Name()
 
-		if _, alive := procs[claudePID]; !alive {
+		if _, alive := table.procs[claudePID]; !alive {
 			w.Status = model.StatusExited
 			continue
 		}
 
-		if hs, ok := hookStatuses[w.PaneID]; ok {
+		if usage != nil {
+			w.CPUPercent, w.MemoryRSS = treeUsage(claudePID, table, prevCPU, elapsed)
+		}
+
+		// A hook file in a pane now running an agent without hooks is left
+		// over from an earlier Claude session there
+		hs, ok := hookStatuses[w.PaneID]
+		if ok && a.StatusSource() == agent.StatusFromHooks {
 			w.Status = mapHookStatus(hs.Status)
+			w.SessionID = hs.SessionID
+			w.LastReply = hs.LastReply
+			switch w.Status {
+			case model.StatusPaused:
+				w.AwaitingDecision = hs.AwaitingDecision
+				w.ToolName = hs.ToolName
+				w.ToolInput = hs.ToolInput
+				w.Message = hs.Message
+			case model.StatusWorking:
+				enrichRunningTool(w, hs, table)
+			}
 		} else {
-			// No hook file yet — session predates hook setup or
-			// hasn't had any events. Default to Idle until a hook fires.
-			w.Status = model.StatusIdle
+			// No hook file — the agent has no hooks, the session predates
+			// hook setup, or it hasn't had any events. Read the pane;
+			// Unknown if nothing on screen is recognizable, e.g. a dialog
+			// we don't know.
+			w.Status = paneStatuses.status(w.PaneID, claudePID, a)
+			w.StatusInferred = w.Status != model.StatusUnknown
+		}
+	}
+}
+
+// enrichRunningTool fills in the tool a Working session is running. The
+// PreToolUse hook names the tool; the process table says whether it spawned
+// a subprocess, and when that started. Without a PreToolUse event (hooks
+// set up by an older ctree), a subprocess newer than the last hook event
+// still shows up by its command line.
+func enrichRunningTool(w *model.Window, hs *hookdata.HookStatus, table *procTable) {
+	w.ToolName = hs.ToolName
+	w.ToolInput = hs.ToolInput
+
+	pid, started := toolProcess(w.ClaudePID, hs.Timestamp, table)
+	switch {
+	case pid != 0:
+		w.ToolPID = pid
+		w.ToolStarted = started
+		if w.ToolName == "" && w.ToolInput == "" {
+			w.ToolInput = table.cmdline(leafProcess(pid, table))
+		}
+	case w.ToolName != "":
+		w.ToolStarted = hs.Timestamp
+	}
+}
+
+// toolProcess returns the oldest child of claudePID started at or after
+// since, i.e. the subprocess of the tool call in flight. Long-lived
+// children such as MCP servers predate the hook event and are skipped.
+func toolProcess(claudePID int, since time.Time, table *procTable) (int, time.Time) {
+	// /proc start times are only as precise as the boot time (1s)
+	since = since.Add(-time.Second)
+
+	var pid int
+	var started time.Time
+	for _, child := range table.children[claudePID] {
+		info := table.procs[child]
+		if info.startTime.IsZero() || info.startTime.Before(since) {
+			continue
+		}
+		if pid == 0 || info.startTime.Before(started) {
+			pid, started = child, info.startTime
+		}
+	}
+	return pid, started
+}
+
+// leafProcess follows the newest child down from pid, reaching the command
+// actually doing the work rather than the shell wrapping it.
+func leafProcess(pid int, table *procTable) int {
+	for {
+		children := table.children[pid]
+		if len(children) == 0 {
+			return pid
+		}
+		newest := children[0]
+		for _, c := range children[1:] {
+			if table.procs[c].startTime.After(table.procs[newest].startTime) {
+				newest = c
+			}
 		}
+		pid = newest
 	}
 }
 
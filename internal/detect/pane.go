@@ -0,0 +1,114 @@
+package detect
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gxespino/ctree/internal/agent"
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/tmux"
+)
+
+// paneAgents identifies agents by pane content when the process tree only
+// shows an anonymous interpreter (e.g., a node process whose script path
+// names no agent).
+var paneAgents = paneIdentifier{seen: make(map[string]paneGuess)}
+
+type paneIdentifier struct {
+	mu   sync.Mutex
+	seen map[string]paneGuess // pane ID → verdict for its interpreter
+}
+
+// paneGuess caches one verdict. The answer can't change while the same
+// process runs, so each interpreter costs at most one capture-pane.
+type paneGuess struct {
+	pid   int
+	agent agent.Agent // nil: not an agent
+}
+
+// negativeGrace is how long an interpreter gets to draw its UI before a
+// pane without agent markers is cached as not running an agent.
+const negativeGrace = 5 * time.Second
+
+// identify returns pid and the adapter whose markers the pane shows, or
+// 0 and nil. pid is the interpreter found under the pane, 0 if none.
+func (p *paneIdentifier) identify(paneID string, pid int, started time.Time) (int, agent.Agent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pid == 0 {
+		delete(p.seen, paneID)
+		return 0, nil
+	}
+	if g, ok := p.seen[paneID]; ok && g.pid == pid {
+		if g.agent == nil {
+			return 0, nil
+		}
+		return pid, g.agent
+	}
+
+	content, err := tmux.CapturePaneVisible(paneID)
+	if err != nil {
+		return 0, nil // don't cache: retry next poll
+	}
+
+	g := paneGuess{pid: pid}
+	for _, a := range agent.All() {
+		if a.MatchPane(content) {
+			g.agent = a
+			break
+		}
+	}
+	// An agent still starting up may not have drawn its UI yet
+	if g.agent != nil || time.Since(started) > negativeGrace {
+		p.seen[paneID] = g
+	}
+
+	if g.agent == nil {
+		return 0, nil
+	}
+	return pid, g.agent
+}
+
+// paneStatuses throttles status inference for agents without hooks to
+// one capture-pane per pane every paneStatusInterval, rather than one
+// per poll.
+var paneStatuses = statusCache{entries: make(map[string]statusEntry)}
+
+// paneStatusInterval is how long an inferred status is reused.
+const paneStatusInterval = 2 * time.Second
+
+type statusCache struct {
+	mu      sync.Mutex
+	entries map[string]statusEntry // pane ID → last inferred status
+}
+
+type statusEntry struct {
+	pid    int // the agent process it was read for
+	status model.Status
+	at     time.Time
+}
+
+// status reads the pane's content through the agent's UI markers, or
+// returns the last reading if it is recent and for the same process.
+func (c *statusCache) status(paneID string, pid int, a agent.Agent) model.Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[paneID]; ok && e.pid == pid && time.Since(e.at) < paneStatusInterval {
+		return e.status
+	}
+	st := model.StatusUnknown
+	if content, err := tmux.CapturePaneVisible(paneID); err == nil {
+		st = a.PaneStatus(content)
+	}
+	c.entries[paneID] = statusEntry{pid: pid, status: st, at: time.Now()}
+	return st
+}
+
+// forget drops the reading for a pane that no longer runs an agent.
+func (c *statusCache) forget(paneID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, paneID)
+}
@@ -0,0 +1,128 @@
+package detect
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of /proc/<pid>/stat times. It is 100 on
+// every mainstream Linux architecture, and reading it properly needs cgo.
+const clockTicks = 100
+
+// readProcTable walks /proc, replacing a fork/exec of ps per poll.
+// Returns nil if /proc can't be read.
+func readProcTable() *procTable {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	boot := bootTime()
+	pageSize := int64(os.Getpagesize())
+	table := newProcTable(procCmdline)
+
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		info, ok := readProcStat(pid, boot, pageSize)
+		if !ok {
+			continue // exited mid-walk
+		}
+		table.add(info)
+	}
+
+	if len(table.procs) == 0 {
+		return nil
+	}
+	return table
+}
+
+// readProcStat reads /proc/<pid>/stat.
+func readProcStat(pid int, boot time.Time, pageSize int64) (processInfo, bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return processInfo{}, false
+	}
+	return parseProcStat(pid, data, boot, pageSize)
+}
+
+// parseProcStat parses the contents of /proc/<pid>/stat.
+func parseProcStat(pid int, data []byte, boot time.Time, pageSize int64) (processInfo, bool) {
+	// Format: pid (comm) state ppid ... — comm may contain spaces and
+	// parentheses, so split around the last ')'.
+	open := bytes.IndexByte(data, '(')
+	close := bytes.LastIndexByte(data, ')')
+	if open < 0 || close < open {
+		return processInfo{}, false
+	}
+	comm := string(data[open+1 : close])
+	fields := strings.Fields(string(data[close+1:]))
+
+	// fields[0] is field 3 (state) in proc(5) numbering
+	const (
+		fPPID      = 4 - 3
+		fUTime     = 14 - 3
+		fSTime     = 15 - 3
+		fStartTime = 22 - 3
+		fRSS       = 24 - 3
+	)
+	if len(fields) <= fRSS {
+		return processInfo{}, false
+	}
+
+	ppid, err := strconv.Atoi(fields[fPPID])
+	if err != nil {
+		return processInfo{}, false
+	}
+	utime, _ := strconv.ParseInt(fields[fUTime], 10, 64)
+	stime, _ := strconv.ParseInt(fields[fSTime], 10, 64)
+	startTicks, _ := strconv.ParseInt(fields[fStartTime], 10, 64)
+	rssPages, _ := strconv.ParseInt(fields[fRSS], 10, 64)
+
+	var start time.Time
+	if !boot.IsZero() {
+		start = boot.Add(time.Duration(startTicks) * time.Second / clockTicks)
+	}
+
+	return processInfo{
+		pid:       pid,
+		ppid:      ppid,
+		comm:      comm,
+		startTime: start,
+		rss:       rssPages * pageSize,
+		cpuTime:   time.Duration(utime+stime) * time.Second / clockTicks,
+	}, true
+}
+
+// procCmdline reads /proc/<pid>/cmdline, whose arguments are NUL-separated.
+func procCmdline(pid int) string {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+	if err != nil {
+		return ""
+	}
+	data = bytes.TrimRight(data, "\x00")
+	return string(bytes.ReplaceAll(data, []byte{0}, []byte{' '}))
+}
+
+// bootTime reads the system boot time from /proc/stat's btime line.
+func bootTime() time.Time {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			if err != nil {
+				return time.Time{}
+			}
+			return time.Unix(secs, 0)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,50 @@
+package detect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	boot := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// ppid 7, utime 250 + stime 50 ticks, started 1000 ticks after boot,
+	// 10 resident pages
+	const rest = " S 7 2 3 0 -1 4194304 0 0 0 0 250 50 0 0 20 0 1 0 1000 2703360 10 18446744073709551615"
+
+	tests := []struct {
+		name string
+		data string
+		comm string
+		ok   bool
+	}{
+		{"plain", "42 (claude)" + rest, "claude", true},
+		{"space in comm", "42 (tmux: server)" + rest, "tmux: server", true},
+		{"paren in comm", "42 (a) b (c))" + rest, "a) b (c)", true},
+		{"empty comm", "42 ()" + rest, "", true},
+		{"no parens", "42 claude" + rest, "", false},
+		{"truncated", "42 (claude) S 7 2 3", "", false},
+		{"bad ppid", "42 (claude) S x 2 3 0 -1 4194304 0 0 0 0 250 50 0 0 20 0 1 0 1000 2703360 10", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok := parseProcStat(42, []byte(tt.data), boot, 4096)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			want := processInfo{
+				pid:       42,
+				ppid:      7,
+				comm:      tt.comm,
+				startTime: boot.Add(10 * time.Second),
+				rss:       10 * 4096,
+				cpuTime:   3 * time.Second,
+			}
+			if info != want {
+				t.Errorf("got %+v, want %+v", info, want)
+			}
+		})
+	}
+}
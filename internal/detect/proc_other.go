@@ -0,0 +1,8 @@
+//go:build !linux
+
+package detect
+
+// readProcTable is Linux-only; other platforms use psProcessTable.
+func readProcTable() *procTable {
+	return nil
+}
@@ -0,0 +1,147 @@
+package detect
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// processInfo holds one process-table entry.
+type processInfo struct {
+	pid       int
+	ppid      int
+	comm      string
+	startTime time.Time     // zero if unknown
+	rss       int64         // resident set size in bytes
+	cpuTime   time.Duration // user + system CPU time consumed so far
+}
+
+// procTable is one snapshot of the process table.
+type procTable struct {
+	procs    map[int]processInfo
+	children map[int][]int // PPID → child PIDs
+
+	// Full command lines are only needed for a few processes per poll,
+	// so they are read on demand and cached for the snapshot's lifetime.
+	cmdlines    map[int]string
+	readCmdline func(pid int) string
+}
+
+func newProcTable(readCmdline func(pid int) string) *procTable {
+	return &procTable{
+		procs:       make(map[int]processInfo),
+		children:    make(map[int][]int),
+		cmdlines:    make(map[int]string),
+		readCmdline: readCmdline,
+	}
+}
+
+func (t *procTable) add(info processInfo) {
+	t.procs[info.pid] = info
+	t.children[info.ppid] = append(t.children[info.ppid], info.pid)
+}
+
+// cmdline returns the process's full command line, space-separated.
+func (t *procTable) cmdline(pid int) string {
+	if c, ok := t.cmdlines[pid]; ok {
+		return c
+	}
+	c := t.readCmdline(pid)
+	t.cmdlines[pid] = c
+	return c
+}
+
+// buildProcessTable snapshots the process table, natively from /proc on
+// Linux, falling back to a single `ps` run elsewhere or if /proc is
+// unavailable. Returns nil if neither works.
+func buildProcessTable() *procTable {
+	if t := readProcTable(); t != nil {
+		return t
+	}
+	return psProcessTable()
+}
+
+// psProcessTable runs `ps` once. comm goes last since it may contain spaces.
+func psProcessTable() *procTable {
+	out, err := exec.Command("ps", "-eo", "pid=,ppid=,rss=,etime=,time=,comm=").Output()
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	table := newProcTable(psCmdline)
+
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rssKB, _ := strconv.ParseInt(fields[2], 10, 64)
+
+		var start time.Time
+		if elapsed, ok := parseEtime(fields[3]); ok {
+			start = now.Add(-elapsed)
+		}
+
+		// cputime shares etime's format
+		cpu, _ := parseEtime(fields[4])
+
+		// comm may contain path, take the basename
+		comm := strings.Join(fields[5:], " ")
+		if idx := strings.LastIndex(comm, "/"); idx >= 0 {
+			comm = comm[idx+1:]
+		}
+
+		table.add(processInfo{
+			pid:       pid,
+			ppid:      ppid,
+			comm:      comm,
+			startTime: start,
+			rss:       rssKB * 1024,
+			cpuTime:   cpu,
+		})
+	}
+
+	return table
+}
+
+// psCmdline fetches one process's command line with `ps`.
+func psCmdline(pid int) string {
+	out, err := exec.Command("ps", "-o", "args=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// parseEtime parses ps elapsed or CPU time, formatted [[dd-]hh:]mm:ss.
+func parseEtime(s string) (time.Duration, bool) {
+	var days int
+	if d, rest, ok := strings.Cut(s, "-"); ok {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return 0, false
+		}
+		days, s = n, rest
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var secs int
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, false
+		}
+		secs = secs*60 + n
+	}
+	return time.Duration(days)*24*time.Hour + time.Duration(secs)*time.Second, true
+}
@@ -0,0 +1,30 @@
+package detect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEtime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"00:05", 5 * time.Second, true},
+		{"12:34", 12*time.Minute + 34*time.Second, true},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second, true},
+		{"2-03:04:05", 2*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second, true},
+		{"", 0, false},
+		{"42", 0, false},
+		{"1:2:3:4", 0, false},
+		{"x-01:02", 0, false},
+		{"01:xx", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseEtime(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseEtime(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
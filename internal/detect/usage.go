@@ -0,0 +1,72 @@
+package detect
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxSampleAge is the oldest previous sample CPU% is measured against,
+// e.g. after usage was switched off for a while.
+const maxSampleAge = 10 * time.Second
+
+// Usage remembers the previous poll's CPU times. CPU% is the CPU time a
+// process consumed between two polls divided by the wall time between
+// them, so each poller keeps its own. The zero value is ready to use.
+type Usage struct {
+	mu  sync.Mutex
+	at  time.Time
+	cpu map[int]time.Duration // PID → CPU time at the last sample
+}
+
+// sample records table's CPU times and returns the previous sample along
+// with the wall time since it. The first sample, or one after a long
+// gap, returns nil.
+func (s *Usage) sample(table *procTable) (map[int]time.Duration, time.Duration) {
+	cpu := make(map[int]time.Duration, len(table.procs))
+	for pid, info := range table.procs {
+		cpu[pid] = info.cpuTime
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, elapsed := s.cpu, now.Sub(s.at)
+	s.cpu, s.at = cpu, now
+	if elapsed > maxSampleAge {
+		return nil, 0
+	}
+	return prev, elapsed
+}
+
+// treeUsage sums CPU% and resident memory over root and all its
+// descendants, so tool subprocesses (test runners, builds) count too.
+// Both are rounded to what the sidebar shows (whole percents, MiB), so
+// unchanged readings compare equal across polls.
+func treeUsage(root int, table *procTable, prevCPU map[int]time.Duration, elapsed time.Duration) (cpuPercent float64, rss int64) {
+	var busy time.Duration
+	var walk func(pid int)
+	walk = func(pid int) {
+		info := table.procs[pid]
+		rss += info.rss
+		if prev, ok := prevCPU[pid]; ok {
+			if info.cpuTime > prev {
+				busy += info.cpuTime - prev
+			}
+		} else if prevCPU != nil {
+			// Started since the last sample: all its CPU time is recent
+			busy += info.cpuTime
+		}
+		for _, child := range table.children[pid] {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	const mib = 1 << 20
+	rss = (rss + mib/2) / mib * mib
+	if prevCPU == nil || elapsed <= 0 {
+		return 0, rss
+	}
+	return math.Round(100 * busy.Seconds() / elapsed.Seconds()), rss
+}
@@ -1,7 +1,9 @@
 package git
 
 import (
+	"fmt"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -23,6 +25,27 @@ var (
 	cacheTTL = 3 * time.Second
 )
 
+// Repo locates the repository a directory belongs to.
+type Repo struct {
+	Toplevel  string // root of the worktree holding the directory
+	CommonDir string // the repository's shared git directory, the same for all its worktrees
+}
+
+type repoEntry struct {
+	repo      Repo
+	err       error
+	fetchedAt time.Time
+}
+
+var (
+	repos   = make(map[string]repoEntry)
+	reposMu sync.Mutex
+
+	// repoTTL is longer than cacheTTL: directories rarely move between
+	// repositories, and this runs for every session on every poll.
+	repoTTL = 30 * time.Second
+)
+
 var shortstatRegex = regexp.MustCompile(
 	`(\d+) files? changed(?:, (\d+) insertions?\(\+\))?(?:, (\d+) deletions?\(-\))?`,
 )
@@ -97,3 +120,67 @@ func parseShortstat(output string) (added, removed int) {
 	}
 	return added, removed
 }
+
+// GetRepo resolves the worktree and repository holding workingDir.
+// Results, including "not a repository", are cached with a TTL.
+func GetRepo(workingDir string) (Repo, error) {
+	reposMu.Lock()
+	if entry, ok := repos[workingDir]; ok && time.Since(entry.fetchedAt) < repoTTL {
+		reposMu.Unlock()
+		return entry.repo, entry.err
+	}
+	reposMu.Unlock()
+
+	repo, err := getRepo(workingDir)
+
+	reposMu.Lock()
+	repos[workingDir] = repoEntry{repo: repo, err: err, fetchedAt: time.Now()}
+	reposMu.Unlock()
+
+	return repo, err
+}
+
+func getRepo(dir string) (Repo, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel", "--git-common-dir").Output()
+	if err != nil {
+		return Repo{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) < 2 {
+		return Repo{}, fmt.Errorf("git rev-parse: unexpected output %q", out)
+	}
+	// --git-common-dir is relative to dir unless it lies elsewhere
+	common := lines[1]
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(dir, common)
+	}
+	return Repo{Toplevel: lines[0], CommonDir: filepath.Clean(common)}, nil
+}
+
+// AddWorktree creates a worktree of the repository holding repoDir at
+// path, checking out a new branch started from base ("" for HEAD).
+func AddWorktree(repoDir, path, branch, base string) error {
+	args := []string{"-C", repoDir, "worktree", "add", "-b", branch, path}
+	if base != "" {
+		args = append(args, base)
+	}
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("git worktree add: %s", msg)
+		}
+		return fmt.Errorf("git worktree add: %w", err)
+	}
+	return nil
+}
+
+// RepoName names a repository by its git directory: the main worktree's
+// directory ("/src/ctree/.git" → "ctree"), or a bare repository's own
+// name without ".git".
+func RepoName(commonDir string) string {
+	name := filepath.Base(commonDir)
+	if name == ".git" {
+		return filepath.Base(filepath.Dir(commonDir))
+	}
+	return strings.TrimSuffix(name, ".git")
+}
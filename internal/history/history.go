@@ -0,0 +1,172 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// SidebarEvent is the event name the sidebar uses when it records a
+// refined status (Unread, Done) that no hook ever reports.
+const SidebarEvent = "sidebar"
+
+// Span is a contiguous stretch of time a session spent in one status.
+type Span struct {
+	Status model.Status
+	Start  time.Time
+	End    time.Time // zero while the span is ongoing
+}
+
+// Duration returns how long the span lasted, measuring ongoing spans up to now.
+func (s Span) Duration() time.Duration {
+	if s.End.IsZero() {
+		return time.Since(s.Start)
+	}
+	return s.End.Sub(s.Start)
+}
+
+// StatusName returns the string a status is logged as.
+// Returns "" for statuses that are never logged.
+func StatusName(s model.Status) string {
+	switch s {
+	case model.StatusWorking:
+		return "working"
+	case model.StatusPaused:
+		return "paused"
+	case model.StatusIdle:
+		return "idle"
+	case model.StatusUnread:
+		return "unread"
+	case model.StatusDone:
+		return "done"
+	case model.StatusExited:
+		return "stopped"
+	default:
+		return ""
+	}
+}
+
+// parseStatus is the inverse of StatusName.
+func parseStatus(name string) model.Status {
+	switch name {
+	case "working":
+		return model.StatusWorking
+	case "paused":
+		return model.StatusPaused
+	case "idle":
+		return model.StatusIdle
+	case "unread":
+		return model.StatusUnread
+	case "done":
+		return model.StatusDone
+	case "stopped":
+		return model.StatusExited
+	default:
+		return model.StatusUnknown
+	}
+}
+
+// Timeline collapses a session's events into status spans, oldest first.
+// Events may come from hooks and sidebars interleaved, so they are sorted
+// by time, and repeated statuses merge into one span.
+func Timeline(events []hookdata.Event) []Span {
+	sorted := make([]hookdata.Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	var spans []Span
+	for _, ev := range sorted {
+		if ev.Skipped {
+			continue
+		}
+		status := parseStatus(ev.Status)
+		if status == model.StatusUnknown {
+			continue
+		}
+		if n := len(spans); n > 0 {
+			if spans[n-1].Status == status {
+				continue
+			}
+			spans[n-1].End = ev.Timestamp
+		}
+		spans = append(spans, Span{Status: status, Start: ev.Timestamp})
+	}
+
+	// An exited session has nothing ongoing
+	if n := len(spans); n > 0 && spans[n-1].Status == model.StatusExited {
+		spans[n-1].End = spans[n-1].Start
+	}
+	return spans
+}
+
+// Totals sums span durations by status.
+func Totals(spans []Span) map[model.Status]time.Duration {
+	totals := make(map[model.Status]time.Duration)
+	for _, s := range spans {
+		totals[s.Status] += s.Duration()
+	}
+	return totals
+}
+
+// FormatDuration renders a duration compactly (e.g., "45s", "2m13s", "1h05m").
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// FormatSpan renders one timeline row: start time, status and duration.
+func FormatSpan(s Span) string {
+	dur := FormatDuration(s.Duration())
+	if s.Status == model.StatusExited {
+		dur = ""
+	} else if s.End.IsZero() {
+		dur += " …"
+	}
+	label := s.Status.String()
+	if pad := 12 - utf8.RuneCountInString(label); pad > 0 {
+		label += strings.Repeat(" ", pad)
+	}
+	return fmt.Sprintf("%s  %s %s", s.Start.Format("15:04:05"), label, dur)
+}
+
+// Summary renders the time split between working, blocked on the user,
+// and finished-but-unread.
+func Summary(spans []Span) string {
+	totals := Totals(spans)
+	parts := []string{"worked " + FormatDuration(totals[model.StatusWorking])}
+	if d := totals[model.StatusPaused]; d > 0 {
+		parts = append(parts, "blocked on you "+FormatDuration(d))
+	}
+	if d := totals[model.StatusUnread]; d > 0 {
+		parts = append(parts, "unread "+FormatDuration(d))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// ParseSince parses a lookback like "90m", "2h" or "3d".
+// time.ParseDuration handles everything except the day suffix.
+func ParseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
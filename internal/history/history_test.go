@@ -0,0 +1,96 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90m", 90 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"3d", 3 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"1.5d", 0, true},
+		{"2x", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	ev := func(s int, status string) hookdata.Event {
+		return hookdata.Event{Status: status, Timestamp: at(s)}
+	}
+
+	tests := []struct {
+		name   string
+		events []hookdata.Event
+		want   []Span
+	}{
+		{"empty", nil, nil},
+		{
+			"ongoing",
+			[]hookdata.Event{ev(0, "working"), ev(10, "idle")},
+			[]Span{
+				{Status: model.StatusWorking, Start: at(0), End: at(10)},
+				{Status: model.StatusIdle, Start: at(10)},
+			},
+		},
+		{
+			"out of order and repeated",
+			[]hookdata.Event{ev(20, "unread"), ev(0, "working"), ev(5, "working"), ev(10, "paused")},
+			[]Span{
+				{Status: model.StatusWorking, Start: at(0), End: at(10)},
+				{Status: model.StatusPaused, Start: at(10), End: at(20)},
+				{Status: model.StatusUnread, Start: at(20)},
+			},
+		},
+		{
+			"skipped and unknown",
+			[]hookdata.Event{
+				ev(0, "working"),
+				{Status: "idle", Timestamp: at(5), Skipped: true},
+				ev(7, "bogus"),
+				ev(10, "done"),
+			},
+			[]Span{
+				{Status: model.StatusWorking, Start: at(0), End: at(10)},
+				{Status: model.StatusDone, Start: at(10)},
+			},
+		},
+		{
+			"exited",
+			[]hookdata.Event{ev(0, "working"), ev(30, "stopped")},
+			[]Span{
+				{Status: model.StatusWorking, Start: at(0), End: at(30)},
+				{Status: model.StatusExited, Start: at(30), End: at(30)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Timeline(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package hook
+
+import (
+	"time"
+
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/state"
+)
+
+const (
+	// localDecisionTimeout bounds how long a permission request waits for
+	// the sidebar. It stays under the 300s PermissionRequest hook timeout
+	// so Claude's terminal dialog still appears if nobody answers.
+	localDecisionTimeout = 4 * time.Minute
+
+	// decisionPollInterval is how often the hook checks for a sidebar decision.
+	decisionPollInterval = 200 * time.Millisecond
+
+	// sidebarMaxAge is how recently a sidebar must have polled for the
+	// hook to wait on it. With no sidebar open, blocking would only hide
+	// the terminal dialog.
+	sidebarMaxAge = 5 * time.Second
+
+	// slackCloseTimeout bounds how long a sidebar decision waits for the
+	// Slack thread to be told about it.
+	slackCloseTimeout = 3 * time.Second
+)
+
+// verdict is a permission decision and where it came from.
+type verdict struct {
+	decision string // "allow", "deny", "ask", or "" (no answer)
+	source   string // "ctree" or "Slack"
+}
+
+// runPermissionRequest marks the pane paused, then waits for the sidebar
+// and/or Slack to decide. "allow" and "deny" are written to stdout for
+// Claude Code; "ask" or no answer falls through to the terminal dialog.
+func runPermissionRequest(paneID string, input hookInput) error {
+	hookdata.ClearDecision(paneID)
+
+	local := state.SidebarActive(sidebarMaxAge)
+	useSlack := state.GetSlack()
+
+	status := hookdata.HookStatus{
+		PaneID:           paneID,
+		SessionID:        input.SessionID,
+		Status:           "paused",
+		Timestamp:        time.Now(),
+		ToolName:         input.ToolName,
+		ToolInput:        summarizeToolInput(input.ToolInput),
+		AwaitingDecision: local,
+	}
+	recordEvent(paneID, "permission-request", status.Status, input)
+	if err := writeStatus(status); err != nil {
+		return err
+	}
+	if !local && !useSlack {
+		return nil
+	}
+
+	v := awaitDecision(paneID, input, local, useSlack)
+	if v.decision == "allow" || v.decision == "deny" {
+		writeDecision(v.decision, v.source)
+		status.Status = "working"
+	}
+	if v.decision == "deny" {
+		// The tool won't run; don't show it as running
+		status.ToolName, status.ToolInput = "", ""
+	}
+
+	status.AwaitingDecision = false
+	status.Timestamp = time.Now()
+
+	ev := newEvent(paneID, "permission-decision", status.Status, input)
+	ev.Decision = v.decision
+	_ = hookdata.AppendEvent(ev)
+
+	return writeStatus(status)
+}
+
+// sidebarAnswer hands the sidebar's decision to the Slack request, so its
+// thread doesn't stay open for an answer nobody needs.
+type sidebarAnswer struct {
+	done     chan struct{} // closed once decision is set
+	decision string
+}
+
+// answered reports whether the sidebar has decided.
+func (s *sidebarAnswer) answered() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// sidebarAnswerText is the Slack thread reply for a sidebar decision.
+func sidebarAnswerText(decision string) string {
+	switch decision {
+	case "allow":
+		return "Approved in the ctree sidebar."
+	case "deny":
+		return "Denied in the ctree sidebar."
+	default:
+		return "Sent to the terminal from the ctree sidebar."
+	}
+}
+
+// awaitDecision returns the first answer from the enabled decision sources.
+// A sidebar "ask" wins immediately so the terminal dialog isn't held up by Slack.
+func awaitDecision(paneID string, input hookInput, local, useSlack bool) verdict {
+	results := make(chan verdict, 2)
+	pending := 0
+
+	sidebar := &sidebarAnswer{done: make(chan struct{})}
+	slackDone := make(chan struct{})
+	if local {
+		pending++
+		go func() {
+			results <- verdict{waitLocalDecision(paneID, localDecisionTimeout), "ctree"}
+		}()
+	}
+	if useSlack {
+		pending++
+		go func() {
+			defer close(slackDone)
+			results <- verdict{handlePermissionRequest(input, sidebar), "Slack"}
+		}()
+	} else {
+		close(slackDone)
+	}
+
+	for ; pending > 0; pending-- {
+		v := <-results
+		if v.decision == "" {
+			continue
+		}
+		if v.source == "ctree" {
+			// Let the Slack request reply in its thread before we exit
+			sidebar.decision = v.decision
+			close(sidebar.done)
+			select {
+			case <-slackDone:
+			case <-time.After(slackCloseTimeout):
+			}
+		}
+		return v
+	}
+	return verdict{}
+}
+
+// waitLocalDecision polls for a decision written by the sidebar.
+// Returns "" if none arrives before the timeout.
+func waitLocalDecision(paneID string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		switch d := hookdata.TakeDecision(paneID); d {
+		case "allow", "deny", "ask":
+			return d
+		}
+		time.Sleep(decisionPollInterval)
+	}
+	return ""
+}
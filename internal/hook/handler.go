@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gxespino/ctree/internal/daemon"
 	"github.com/gxespino/ctree/internal/hookdata"
 	"github.com/gxespino/ctree/internal/slack"
 	"github.com/gxespino/ctree/internal/state"
@@ -17,15 +18,19 @@ import (
 type hookInput struct {
 	SessionID        string         `json:"session_id"`
 	NotificationType string         `json:"notification_type"`
+	Message          string         `json:"message"`
 	ToolName         string         `json:"tool_name"`
 	ToolInput        map[string]any `json:"tool_input"`
 	CWD              string         `json:"cwd"`
+	TranscriptPath   string         `json:"transcript_path"`
 }
 
 // Run handles the "ctree hook <event>" subcommand.
 // Reads $TMUX_PANE for pane identification, reads Claude Code's JSON
-// payload from stdin, and writes the status to a hook data file.
-// For permission-request events, optionally forwards to Slack for remote approval.
+// payload from stdin, and delivers the status to the ctree daemon (or a
+// hook data file when no daemon is running).
+// For permission-request events, waits for a decision from the sidebar
+// and/or Slack for remote approval.
 func Run(event string) error {
 	paneID := os.Getenv("TMUX_PANE")
 	if paneID == "" {
@@ -42,21 +47,25 @@ func Run(event string) error {
 		_ = json.Unmarshal(data, &input) // best-effort
 	}
 
-	// Slack: handle permission requests (bidirectional) and notifications (one-way).
+	// Permission requests block until the sidebar or Slack answers, or
+	// fall through to Claude's terminal dialog.
+	if event == "permission-request" {
+		return runPermissionRequest(paneID, input)
+	}
+
+	// Slack: one-way notification when Claude asks a question.
 	// Only fires when the user has toggled Slack on via the TUI (s key).
-	if state.GetSlack() {
-		if event == "permission-request" {
-			if decision := handlePermissionRequest(input); decision != "" {
-				writeDecision(decision)
-			}
-		}
-		if event == "notification" && input.NotificationType == "elicitation_dialog" {
-			handleNotification(input)
-		}
+	if state.GetSlack() && event == "notification" && input.NotificationType == "elicitation_dialog" {
+		handleNotification(input)
+	}
+
+	if event == "session-end" {
+		defer hookdata.PruneHistory()
 	}
 
 	status := mapEventToStatus(event, input.NotificationType)
 	if status == "" {
+		recordEvent(paneID, event, "", input)
 		return nil
 	}
 
@@ -67,26 +76,77 @@ func Run(event string) error {
 	if status == "idle" {
 		if existing, _ := hookdata.Read(paneID); existing != nil {
 			if existing.Status == "paused" && time.Since(existing.Timestamp) < 10*time.Second {
+				recordSkipped(paneID, event, status, input)
 				return nil
 			}
 		}
 	}
 
-	return hookdata.Write(hookdata.HookStatus{
+	recordEvent(paneID, event, status, input)
+	hs := hookdata.HookStatus{
 		PaneID:    paneID,
 		SessionID: input.SessionID,
 		Status:    status,
 		Timestamp: time.Now(),
-	})
+		Message:   input.Message,
+	}
+	// Claude's reply, kept for the recently exited list
+	if event == "stop" {
+		hs.LastReply = lastReply(input.TranscriptPath)
+	}
+	// The tool about to run stays in the status file until PostToolUse
+	// (or any other event) replaces it, so the sidebar can show it.
+	if event == "pre-tool-use" {
+		hs.ToolName = input.ToolName
+		hs.ToolInput = summarizeToolInput(input.ToolInput)
+	}
+	return writeStatus(hs)
+}
+
+// writeStatus delivers a status through the ctree daemon so sidebars update
+// instantly, falling back to writing the status file when no daemon is running.
+func writeStatus(status hookdata.HookStatus) error {
+	if err := daemon.Publish(status); err == nil {
+		return nil
+	}
+	return hookdata.Write(status)
+}
+
+// recordEvent appends the event to the session's history log (best-effort).
+func recordEvent(paneID, event, status string, input hookInput) {
+	_ = hookdata.AppendEvent(newEvent(paneID, event, status, input))
+}
+
+// recordSkipped logs an event whose status was deliberately not written.
+func recordSkipped(paneID, event, status string, input hookInput) {
+	ev := newEvent(paneID, event, status, input)
+	ev.Skipped = true
+	_ = hookdata.AppendEvent(ev)
+}
+
+func newEvent(paneID, event, status string, input hookInput) hookdata.Event {
+	return hookdata.Event{
+		Event:     event,
+		Status:    status,
+		ToolName:  input.ToolName,
+		Timestamp: time.Now(),
+		SessionID: input.SessionID,
+		PaneID:    paneID,
+		CWD:       input.CWD,
+	}
 }
 
 // handlePermissionRequest sends a Slack message and waits for a threaded reply.
-// Returns "allow", "deny", "ask", or "" (fall through to terminal).
-func handlePermissionRequest(input hookInput) string {
+// Returns "allow", "deny", "ask", or "" (fall through to terminal). If
+// the sidebar answers first, the thread says so and stops waiting.
+func handlePermissionRequest(input hookInput, sidebar *sidebarAnswer) string {
 	cfg, err := slack.LoadConfig()
 	if cfg == nil || err != nil {
 		return ""
 	}
+	if sidebar.answered() {
+		return "" // nothing to ask on Slack anymore
+	}
 
 	text := formatPermissionMessage(input)
 	threadTS, err := slack.SendMessage(cfg, text)
@@ -95,7 +155,11 @@ func handlePermissionRequest(input hookInput) string {
 		return ""
 	}
 
-	reply, err := slack.WaitForReply(cfg, threadTS, 5*time.Minute)
+	reply, err := slack.WaitForReply(cfg, threadTS, 5*time.Minute, sidebar.done)
+	if sidebar.answered() {
+		_ = slack.ReplyInThread(cfg, threadTS, sidebarAnswerText(sidebar.decision))
+		return ""
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ctree: slack poll failed: %v\n", err)
 		return ""
@@ -127,12 +191,13 @@ func handleNotification(input hookInput) {
 
 // writeDecision outputs a permission decision as JSON to stdout for Claude Code.
 // PermissionRequest hooks use decision.behavior ("allow"/"deny"), not permissionDecision.
-func writeDecision(decision string) {
+// source names where the decision came from ("Slack", "ctree") for the denial message.
+func writeDecision(decision, source string) {
 	output := map[string]any{
 		"behavior": decision,
 	}
 	if decision == "deny" {
-		output["message"] = "Denied via Slack"
+		output["message"] = "Denied via " + source
 	}
 	resp := map[string]any{
 		"hookSpecificOutput": map[string]any{
@@ -189,6 +254,20 @@ func formatToolInput(input map[string]any) string {
 	return s
 }
 
+// summarizeToolInput reduces formatToolInput's output to a single line
+// short enough to store in the status file and show in a sidebar row.
+func summarizeToolInput(input map[string]any) string {
+	if input == nil {
+		return ""
+	}
+	s := strings.Join(strings.Fields(formatToolInput(input)), " ")
+	const maxLen = 200
+	if r := []rune(s); len(r) > maxLen {
+		s = string(r[:maxLen]) + "…"
+	}
+	return s
+}
+
 // parseDecision normalizes a Slack reply to a Claude permission decision.
 // PermissionRequest hooks only support "allow" or "deny".
 func parseDecision(reply string) string {
@@ -220,6 +299,8 @@ func mapEventToStatus(event, notificationType string) string {
 		return "idle"
 	case "permission-request":
 		return "paused"
+	case "pre-tool-use":
+		return "working"
 	case "post-tool-use":
 		return "working"
 	case "session-end":
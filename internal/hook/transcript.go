@@ -0,0 +1,90 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+)
+
+// transcriptTail is how much of the end of a transcript lastReply reads.
+// The last reply is near the end; transcripts grow to megabytes.
+const transcriptTail = 256 << 10
+
+// maxReplyLen caps the reply kept in the status file.
+const maxReplyLen = 300
+
+// transcriptLine is the subset of a Claude Code transcript entry we read.
+type transcriptLine struct {
+	Type    string `json:"type"`
+	Message struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// lastReply returns the text of the last assistant message in a Claude
+// Code transcript (JSON lines), or "" if there is none or it can't be read.
+func lastReply(path string) string {
+	if path == "" {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > transcriptTail {
+		_, _ = f.Seek(-transcriptTail, io.SeekEnd)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return ""
+	}
+
+	// Newest last; a partial first line from the seek just fails to parse
+	lines := bytes.Split(data, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var line transcriptLine
+		if json.Unmarshal(lines[i], &line) != nil || line.Type != "assistant" {
+			continue
+		}
+		if text := contentText(line.Message.Content); text != "" {
+			return shortenReply(text)
+		}
+	}
+	return ""
+}
+
+// contentText joins the text blocks of a message's content, which is a
+// plain string or a list of blocks (text, tool_use, ...).
+func contentText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &blocks) != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" && strings.TrimSpace(b.Text) != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// shortenReply flattens a reply to one line of at most maxReplyLen runes.
+func shortenReply(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > maxReplyLen {
+		return string(r[:maxReplyLen-1]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,209 @@
+package hookdata
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxHistorySize is the size at which a session log is rotated to <id>.jsonl.1.
+	// Only one rotated generation is kept, so a session holds at most twice this.
+	maxHistorySize = 1 << 20
+
+	// maxHistoryAge is how long a session log survives after its last event.
+	maxHistoryAge = 7 * 24 * time.Hour
+
+	// maxHistoryTotal caps the whole history directory. Oldest logs go first.
+	maxHistoryTotal = 64 << 20
+)
+
+// Event is one hook event recorded in a session's append-only history log.
+type Event struct {
+	Event     string    `json:"event"`
+	Status    string    `json:"status,omitempty"`
+	ToolName  string    `json:"tool_name,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	SessionID string    `json:"session_id,omitempty"`
+	PaneID    string    `json:"pane_id,omitempty"`
+	CWD       string    `json:"cwd,omitempty"`
+
+	// Decision is the permission decision for "permission-decision" events.
+	Decision string `json:"decision,omitempty"`
+
+	// Skipped is set when the status was not written, e.g. a Stop "idle"
+	// suppressed by a recent PermissionRequest "paused".
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// SessionLog describes one session's history log on disk.
+type SessionLog struct {
+	ID      string
+	ModTime time.Time
+	Size    int64
+}
+
+// HistoryDir returns the history directory path (~/.config/ctree/history/).
+func HistoryDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "ctree", "history")
+}
+
+// HistoryKey returns the log ID for an event: the Claude session ID, or the
+// pane ID for events that arrive without one.
+func HistoryKey(sessionID, paneID string) string {
+	if sessionID != "" {
+		return sessionID
+	}
+	return "pane-" + strings.TrimPrefix(paneID, "%")
+}
+
+// AppendEvent appends an event to its session's log, rotating the log
+// once it grows past maxHistorySize.
+func AppendEvent(ev Event) error {
+	dir := HistoryDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, historyFile(HistoryKey(ev.SessionID, ev.PaneID)))
+	if info, err := os.Stat(path); err == nil && info.Size() >= maxHistorySize {
+		_ = os.Rename(path, path+".1")
+	}
+
+	// O_APPEND keeps single-line writes from concurrent hooks intact
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory returns a session's events at or after since, oldest first.
+// Includes the rotated generation. Returns nil if the session has no log.
+func ReadHistory(id string, since time.Time) ([]Event, error) {
+	path := filepath.Join(HistoryDir(), historyFile(id))
+
+	var events []Event
+	for _, p := range []string{path + ".1", path} {
+		evs, err := readEvents(p, since)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, evs...)
+	}
+	return events, nil
+}
+
+// ReadAllHistory returns events at or after since for every session log
+// modified since then, keyed by log ID.
+func ReadAllHistory(since time.Time) map[string][]Event {
+	result := make(map[string][]Event)
+	for _, s := range ListHistory() {
+		if s.ModTime.Before(since) {
+			continue
+		}
+		events, err := ReadHistory(s.ID, since)
+		if err != nil || len(events) == 0 {
+			continue
+		}
+		result[s.ID] = events
+	}
+	return result
+}
+
+// ListHistory returns all session logs, most recently active first.
+func ListHistory() []SessionLog {
+	entries, err := os.ReadDir(HistoryDir())
+	if err != nil {
+		return nil
+	}
+
+	var logs []SessionLog
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		logs = append(logs, SessionLog{
+			ID:      strings.TrimSuffix(e.Name(), ".jsonl"),
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].ModTime.After(logs[j].ModTime)
+	})
+	return logs
+}
+
+// PruneHistory removes session logs idle for longer than maxHistoryAge,
+// then drops the oldest logs until the directory fits maxHistoryTotal.
+func PruneHistory() {
+	dir := HistoryDir()
+	var total int64
+	for _, s := range ListHistory() {
+		path := filepath.Join(dir, historyFile(s.ID))
+		size := s.Size
+		if info, err := os.Stat(path + ".1"); err == nil {
+			size += info.Size()
+		}
+
+		if time.Since(s.ModTime) > maxHistoryAge || total+size > maxHistoryTotal {
+			os.Remove(path)
+			os.Remove(path + ".1")
+			continue
+		}
+		total += size
+	}
+}
+
+// readEvents parses a JSONL log, skipping malformed lines.
+// A missing file yields no events and no error.
+func readEvents(path string, since time.Time) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		if ev.Timestamp.Before(since) {
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
+
+// historyFile converts a log ID to its filename (e.g., "abc-123" → "abc-123.jsonl").
+func historyFile(id string) string {
+	return strings.ReplaceAll(id, string(filepath.Separator), "_") + ".jsonl"
+}
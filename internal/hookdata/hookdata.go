@@ -14,6 +14,22 @@ type HookStatus struct {
 	SessionID string    `json:"session_id,omitempty"`
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// ToolName and ToolInput describe the pending tool call for permission
+	// requests, or the running one after PreToolUse. ToolInput is a
+	// one-line summary (command, file path, ...).
+	ToolName  string `json:"tool_name,omitempty"`
+	ToolInput string `json:"tool_input,omitempty"`
+
+	// Message is the notification text Claude showed, if any.
+	Message string `json:"message,omitempty"`
+
+	// LastReply is the start of Claude's last reply, set by Stop.
+	LastReply string `json:"last_reply,omitempty"`
+
+	// AwaitingDecision is set while a PermissionRequest hook is blocked
+	// waiting for the sidebar to allow or deny the tool call.
+	AwaitingDecision bool `json:"awaiting_decision,omitempty"`
 }
 
 // Dir returns the hooks directory path (~/.config/ctree/hooks/).
@@ -25,13 +41,17 @@ func Dir() string {
 // Write atomically writes a status file for the given pane.
 // Uses temp file + rename for atomic writes on the same filesystem.
 func Write(status HookStatus) error {
-	dir := Dir()
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	data, err := json.Marshal(status)
+	if err != nil {
 		return err
 	}
+	return writeAtomic(fileKey(status.PaneID), data)
+}
 
-	data, err := json.Marshal(status)
-	if err != nil {
+// writeAtomic writes data to name inside Dir() via temp file + rename.
+func writeAtomic(name string, data []byte) error {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
 	}
 
@@ -52,7 +72,7 @@ func Write(status HookStatus) error {
 		return err
 	}
 
-	return os.Rename(tmpName, filepath.Join(dir, fileKey(status.PaneID)))
+	return os.Rename(tmpName, filepath.Join(dir, name))
 }
 
 // Read reads the status file for a pane. Returns nil if not found.
@@ -101,8 +121,11 @@ func ReadAll() map[string]*HookStatus {
 	return result
 }
 
-// Cleanup removes status files older than maxAge.
-func Cleanup(maxAge time.Duration) {
+// Cleanup removes the status files of panes that are no longer open, once
+// they are older than maxAge: a hook can fire for a pane before list-panes
+// shows it. Files of open panes stay however old they are, since an idle
+// session's last hook holds its status and session ID.
+func Cleanup(open map[string]bool, maxAge time.Duration) {
 	entries, err := os.ReadDir(Dir())
 	if err != nil {
 		return
@@ -125,12 +148,36 @@ func Cleanup(maxAge time.Duration) {
 			continue
 		}
 
-		if hs.IsStale(maxAge) {
+		if !open[hs.PaneID] && hs.IsStale(maxAge) {
 			os.Remove(path)
 		}
 	}
 }
 
+// WriteDecision records a permission decision ("allow", "deny" or "ask")
+// for the pane's pending PermissionRequest hook, which consumes it.
+func WriteDecision(paneID, decision string) error {
+	return writeAtomic(decisionKey(paneID), []byte(decision))
+}
+
+// TakeDecision reads and removes the pending decision for a pane.
+// Returns "" if no decision has been written.
+func TakeDecision(paneID string) string {
+	path := filepath.Join(Dir(), decisionKey(paneID))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	os.Remove(path)
+	return strings.TrimSpace(string(data))
+}
+
+// ClearDecision removes any leftover decision for a pane, so a keypress
+// meant for an earlier request can't answer a new one.
+func ClearDecision(paneID string) {
+	os.Remove(filepath.Join(Dir(), decisionKey(paneID)))
+}
+
 // IsStale returns true if the status is older than the given duration.
 func (h *HookStatus) IsStale(maxAge time.Duration) bool {
 	return time.Since(h.Timestamp) > maxAge
@@ -140,3 +187,8 @@ func (h *HookStatus) IsStale(maxAge time.Duration) bool {
 func fileKey(paneID string) string {
 	return paneID + ".json"
 }
+
+// decisionKey converts a pane ID to its decision filename (e.g., "%25" → "%25.decision").
+func decisionKey(paneID string) string {
+	return paneID + ".decision"
+}
@@ -41,32 +41,74 @@ func (s Status) String() string {
 	}
 }
 
-// Window represents a tmux window that is (or was) running Claude.
+// Window represents a tmux window that is (or was) running Claude or
+// another coding agent.
 type Window struct {
 	SessionName string
 	WindowIndex int
 	WindowID    string
 	WindowName  string
 	PaneID      string
+	PaneIndex   int
 	PanePID     int
+	SessionID   string // Claude session ID, from hook data
 
 	WorkingDir   string
 	Status       Status
 	LastActivity time.Time
 
+	// StatusInferred is set when Status was read off the pane's content
+	// rather than reported by a hook, and may be wrong.
+	StatusInferred bool
+
 	GitBranch  string
 	GitAdded   int
 	GitRemoved int
 	GitDirty   bool
 
+	// GitRoot is the top of the worktree holding WorkingDir, and
+	// GitCommonDir the repository's git directory, shared by all its
+	// worktrees. Both are empty outside a repository.
+	GitRoot      string
+	GitCommonDir string
+
+	// ClaudePID and IsClaudePane refer to whichever agent the pane runs;
+	// Agent names its adapter ("claude", "codex", "aider", "gemini").
 	ClaudePID      int
 	IsClaudePane   bool
 	IsActiveWindow bool
+	IsActivePane   bool // the focused pane of its window
+	Agent          string
+
+	// CPUPercent and MemoryRSS cover the Claude process and every
+	// descendant, including tool subprocesses. CPUPercent can exceed 100
+	// on multiple cores; MemoryRSS is in bytes.
+	CPUPercent float64
+	MemoryRSS  int64
+
+	// ToolName, ToolInput and Message describe what a Paused session is
+	// asking for: the pending tool call, or the question Claude showed.
+	// For a Working session, ToolName and ToolInput are the running tool
+	// (ToolInput alone is a subprocess command line when no hook said
+	// which tool it is), started at ToolStarted.
+	ToolName    string
+	ToolInput   string
+	Message     string
+	ToolStarted time.Time
+	ToolPID     int // the tool's subprocess, if it spawned one
+
+	// AwaitingDecision is true while a permission request is blocked on
+	// the sidebar to allow or deny it.
+	AwaitingDecision bool
+
+	// LastReply is the start of the agent's last reply, when a hook
+	// reported it since the session's last prompt.
+	LastReply string
 }
 
 // FilterValue implements bubbles/list.Item for search/filter.
 func (w Window) FilterValue() string {
-	return w.WindowName + " " + w.WorkingDir + " " + w.GitBranch
+	return w.WindowName + " " + w.WorkingDir + " " + w.GitBranch + " " + w.Agent
 }
 
 // Title returns the display name for this window.
@@ -89,11 +131,59 @@ func (w Window) Description() string {
 	return strings.Join(parts, " · ")
 }
 
+// Request returns a one-line description of what a Paused session is
+// asking for (e.g., "Bash: rm -rf build/"), or "" if unknown.
+func (w Window) Request() string {
+	switch {
+	case w.ToolName != "" && w.ToolInput != "":
+		return w.ToolName + ": " + w.ToolInput
+	case w.ToolName != "":
+		return w.ToolName
+	case w.ToolInput != "":
+		return w.ToolInput
+	default:
+		return w.Message
+	}
+}
+
+// AcceptsInput reports whether the session sits at its prompt, so typed
+// text becomes a new message rather than landing in a dialog or being
+// queued behind work in progress.
+func (w Window) AcceptsInput() bool {
+	switch w.Status {
+	case StatusIdle, StatusUnread, StatusDone:
+		return true
+	default:
+		return false
+	}
+}
+
 // Target returns the tmux target string for this window.
 func (w Window) Target() string {
 	return fmt.Sprintf("%s:%d", w.SessionName, w.WindowIndex)
 }
 
+// ExitedSession is an agent session whose agent quit or whose pane was
+// closed, remembered so it can be resumed.
+type ExitedSession struct {
+	SessionID  string    `json:"session_id"`
+	Agent      string    `json:"agent,omitempty"`
+	WorkingDir string    `json:"cwd"`
+	GitBranch  string    `json:"branch,omitempty"`
+	LastStatus Status    `json:"last_status"`
+	Message    string    `json:"message,omitempty"` // the agent's last reply
+	ExitedAt   time.Time `json:"exited_at"`
+}
+
+// Title returns the display name for this session, like Window.Title.
+func (e ExitedSession) Title() string {
+	parts := strings.Split(e.WorkingDir, "/")
+	if last := parts[len(parts)-1]; last != "" {
+		return last
+	}
+	return e.WorkingDir
+}
+
 // RelativeTime formats a time as a human-readable relative duration.
 func RelativeTime(t time.Time) string {
 	d := time.Since(t)
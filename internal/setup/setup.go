@@ -22,6 +22,7 @@ var hookEvents = map[string]hookDef{
 	"Stop":              {arg: "stop", timeout: 5},
 	"Notification":      {arg: "notification", timeout: 5},
 	"PermissionRequest": {arg: "permission-request", timeout: 300},
+	"PreToolUse":        {arg: "pre-tool-use", timeout: 5},
 	"PostToolUse":       {arg: "post-tool-use", timeout: 5},
 	"SessionEnd":        {arg: "session-end", timeout: 5},
 }
@@ -57,8 +57,9 @@ func ReplyInThread(cfg *Config, threadTS, text string) error {
 }
 
 // WaitForReply polls for a threaded reply from a human user.
-// Returns the reply text, or "" if timeout is reached (not an error).
-func WaitForReply(cfg *Config, threadTS string, timeout time.Duration) (string, error) {
+// Returns the reply text, or "" if timeout is reached or cancel is
+// closed (not an error).
+func WaitForReply(cfg *Config, threadTS string, timeout time.Duration, cancel <-chan struct{}) (string, error) {
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
@@ -69,7 +70,11 @@ func WaitForReply(cfg *Config, threadTS string, timeout time.Duration) (string,
 		if reply != "" {
 			return reply, nil
 		}
-		time.Sleep(pollInterval)
+		select {
+		case <-time.After(pollInterval):
+		case <-cancel:
+			return "", nil
+		}
 	}
 
 	return "", nil
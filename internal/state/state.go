@@ -4,13 +4,24 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/gxespino/ctree/internal/model"
 )
 
+// Version is the current state.json format. Version 1 keyed LastSeen by
+// tmux window target ("session:index"); version 2 keys it by pane ID, so
+// agents sharing a window are tracked apart.
+const Version = 2
+
 // PersistentState is serialized to ~/.config/ctree/state.json.
 type PersistentState struct {
 	LastSeen map[string]time.Time `json:"last_seen"`
 	Version  int                  `json:"version"`
+
+	// Exited lists recently ended sessions, most recent first.
+	Exited []model.ExitedSession `json:"exited,omitempty"`
 }
 
 func configDir() string {
@@ -53,15 +64,66 @@ func Save(s *PersistentState) error {
 	return os.WriteFile(statePath(), data, 0o644)
 }
 
-// MarkSeen records that the user jumped to this window at the current time.
-func (s *PersistentState) MarkSeen(windowID string) {
-	s.LastSeen[windowID] = time.Now()
+// MarkSeen records that the user looked at this pane at the current time.
+func (s *PersistentState) MarkSeen(paneID string) {
+	s.LastSeen[paneID] = time.Now()
+}
+
+// RecordExit remembers an ended session, replacing any older entry for
+// the same session ID.
+func (s *PersistentState) RecordExit(e model.ExitedSession) {
+	s.ForgetExit(e.SessionID)
+	s.Exited = append([]model.ExitedSession{e}, s.Exited...)
+}
+
+// ForgetExit drops an ended session, e.g. once it has been resumed.
+// Reports whether it was there.
+func (s *PersistentState) ForgetExit(sessionID string) bool {
+	for i, e := range s.Exited {
+		if e.SessionID == sessionID {
+			s.Exited = append(s.Exited[:i], s.Exited[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// TrimExited keeps at most max ended sessions, none older than maxAge.
+func (s *PersistentState) TrimExited(max int, maxAge time.Duration) {
+	kept := s.Exited[:0]
+	for _, e := range s.Exited {
+		if len(kept) < max && time.Since(e.ExitedAt) < maxAge {
+			kept = append(kept, e)
+		}
+	}
+	s.Exited = kept
+}
+
+// Migrate upgrades state loaded in an older format. panesOf returns the
+// IDs of the agent panes in a tmux window target, to rekey a version 1
+// LastSeen; entries for windows that no longer exist are dropped.
+// Reports whether anything changed.
+func (s *PersistentState) Migrate(panesOf func(target string) []string) bool {
+	if s.Version >= Version {
+		return false
+	}
+	if s.Version < 2 {
+		old := s.LastSeen
+		s.LastSeen = make(map[string]time.Time, len(old))
+		for target, at := range old {
+			for _, paneID := range panesOf(target) {
+				s.LastSeen[paneID] = at
+			}
+		}
+	}
+	s.Version = Version
+	return true
 }
 
 func newState() *PersistentState {
 	return &PersistentState{
 		LastSeen: make(map[string]time.Time),
-		Version:  1,
+		Version:  Version,
 	}
 }
 
@@ -86,6 +148,48 @@ func GetPreview() bool {
 	return err == nil
 }
 
+// usageFlagPath is a zero-byte file whose existence means "show CPU/memory".
+func usageFlagPath() string {
+	return filepath.Join(configDir(), "usage")
+}
+
+// SetUsage persists the CPU/memory line toggle so all ctree instances stay in sync.
+func SetUsage(on bool) {
+	if on {
+		_ = os.MkdirAll(configDir(), 0o755)
+		_ = os.WriteFile(usageFlagPath(), nil, 0o644)
+	} else {
+		_ = os.Remove(usageFlagPath())
+	}
+}
+
+// GetUsage reads the shared CPU/memory line toggle state.
+func GetUsage() bool {
+	_, err := os.Stat(usageFlagPath())
+	return err == nil
+}
+
+// groupingPath holds the sidebar's grouping mode ("repo", "session",
+// "status" or "none"). No file = the default, by repository.
+func groupingPath() string {
+	return filepath.Join(configDir(), "grouping")
+}
+
+// SetGrouping persists the grouping mode so all ctree instances stay in sync.
+func SetGrouping(mode string) {
+	_ = os.MkdirAll(configDir(), 0o755)
+	_ = os.WriteFile(groupingPath(), []byte(mode+"\n"), 0o644)
+}
+
+// GetGrouping reads the shared grouping mode, or "" if never set.
+func GetGrouping() string {
+	data, err := os.ReadFile(groupingPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
 // bellMutedFlagPath is a zero-byte file whose existence means "bells muted".
 // No file = bells ON (preserves default behavior).
 func bellMutedFlagPath() string {
@@ -129,3 +233,27 @@ func GetSlack() bool {
 	_, err := os.Stat(slackFlagPath())
 	return err == nil // no file = slack off
 }
+
+// sidebarHeartbeatPath is a zero-byte file touched by every running sidebar
+// on each poll. Its mtime tells hook processes whether anyone is watching.
+func sidebarHeartbeatPath() string {
+	return filepath.Join(configDir(), "sidebar-heartbeat")
+}
+
+// TouchSidebar records that a sidebar is running and can answer permission requests.
+func TouchSidebar() {
+	now := time.Now()
+	if err := os.Chtimes(sidebarHeartbeatPath(), now, now); err != nil {
+		_ = os.MkdirAll(configDir(), 0o755)
+		_ = os.WriteFile(sidebarHeartbeatPath(), nil, 0o644)
+	}
+}
+
+// SidebarActive reports whether any sidebar has polled within maxAge.
+func SidebarActive(maxAge time.Duration) bool {
+	info, err := os.Stat(sidebarHeartbeatPath())
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) < maxAge
+}
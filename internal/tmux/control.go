@@ -0,0 +1,303 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// resyncInterval re-reads the pane table even without notifications,
+// since tmux sends none when a pane's working directory changes.
+const resyncInterval = 10 * time.Second
+
+// structuralEvents are the control-mode notifications after which the
+// pane table or focus may have changed and is re-read.
+var structuralEvents = map[string]bool{
+	"%window-add":              true,
+	"%window-close":            true,
+	"%window-renamed":          true,
+	"%unlinked-window-add":     true,
+	"%unlinked-window-close":   true,
+	"%unlinked-window-renamed": true,
+	"%session-changed":         true,
+	"%sessions-changed":        true,
+	"%session-renamed":         true,
+	"%session-window-changed":  true, // focus moved to another window
+	"%window-pane-changed":     true,
+	"%layout-change":           true, // panes split or killed
+}
+
+// Control is a tmux control-mode client (tmux -C) that keeps an in-memory
+// pane table current from tmux's notifications, so callers needn't run
+// list-panes on every poll. The table is re-read over the control
+// connection itself, so keeping it current forks nothing.
+type Control struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	panes   []model.Window
+	synced  bool             // panes holds a complete table
+	pending []func([]string) // reply handlers, in the order commands were sent
+
+	listing      bool // a list-panes reply is outstanding
+	relistNeeded bool // something changed while it was
+
+	changes chan struct{} // cap 1: the pane table or focus changed
+	done    chan struct{} // closed when the control client exits
+}
+
+// StartControl attaches a control-mode client to the tmux server. It
+// fails if tmux isn't running, has no sessions to attach to, or is older
+// than 3.2.
+//
+// The client is a real attach to one session: it runs client-attached
+// hooks and counts in #{session_attached}, and %output only arrives for
+// that session's panes, so activity elsewhere is picked up by the
+// periodic relist.
+func StartControl() (*Control, error) {
+	// ignore-size (tmux 3.2): a control client has no terminal, and
+	// mustn't shrink windows to its default 80x24
+	if !versionAtLeast(3, 2) {
+		return nil, errors.New("tmux control mode needs tmux 3.2 or later")
+	}
+	cmd := exec.Command("tmux", "-C", "attach-session", "-f", "ignore-size")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("tmux -C: %w", err)
+	}
+
+	c := &Control{
+		cmd:     cmd,
+		stdin:   stdin,
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go c.read(stdout)
+	go c.resync()
+	c.relist()
+	return c, nil
+}
+
+// Panes returns a copy of the pane table, in the same form as
+// ListAllPanes. ok is false until the first listing arrives.
+func (c *Control) Panes() (panes []model.Window, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		return nil, false
+	}
+	return append([]model.Window(nil), c.panes...), true
+}
+
+// Changes signals when windows or panes were added, closed or renamed,
+// or focus moved.
+func (c *Control) Changes() <-chan struct{} {
+	return c.changes
+}
+
+// Done is closed when the control client exits, e.g. the tmux server quit.
+func (c *Control) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close detaches the control client and waits for it to exit.
+func (c *Control) Close() error {
+	err := c.stdin.Close() // EOF detaches
+	<-c.done
+	return err
+}
+
+// read parses control-mode output: command replies framed by
+// %begin/%end (or %error), and notification lines outside them.
+func (c *Control) read(r io.Reader) {
+	defer close(c.done)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024) // %output lines can be long
+
+	var block []string
+	inBlock, ours := false, false
+	for sc.Scan() {
+		line := sc.Text()
+		if inBlock {
+			end := strings.HasPrefix(line, "%end ")
+			if end || strings.HasPrefix(line, "%error ") {
+				inBlock = false
+				if ours {
+					c.reply(block, end)
+				}
+				block = nil
+				continue
+			}
+			block = append(block, line)
+			continue
+		}
+
+		if strings.HasPrefix(line, "%begin ") {
+			// %begin time number flags; flags is 1 for commands we sent,
+			// 0 for the attach itself
+			inBlock = true
+			ours = strings.HasSuffix(line, " 1")
+			continue
+		}
+		c.notify(line)
+	}
+	c.cmd.Wait()
+}
+
+// notify handles one notification line.
+func (c *Control) notify(line string) {
+	name, rest, _ := strings.Cut(line, " ")
+	switch {
+	case name == "%output":
+		paneID, _, _ := strings.Cut(rest, " ")
+		c.touch(paneID)
+	case structuralEvents[name]:
+		c.relist()
+	}
+}
+
+// touch records output in a pane as activity in its window, as tmux's
+// window_activity does, without signaling: output is far too frequent.
+func (c *Control) touch(paneID string) {
+	now := time.Now().Truncate(time.Second)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	windowID := ""
+	for _, p := range c.panes {
+		if p.PaneID == paneID {
+			windowID = p.WindowID
+			break
+		}
+	}
+	if windowID == "" {
+		return
+	}
+	for i := range c.panes {
+		if c.panes[i].WindowID == windowID {
+			c.panes[i].LastActivity = now
+		}
+	}
+}
+
+// relist asks tmux for the pane table. Bursts of notifications (a new
+// window brings several) collapse into one outstanding request plus at
+// most one more.
+func (c *Control) relist() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listing {
+		c.relistNeeded = true
+		return
+	}
+	c.listing = true
+	c.sendLocked("list-panes -a -F '"+paneFormat+"'", c.listed)
+}
+
+// listed installs a list-panes reply (nil if tmux returned an error).
+func (c *Control) listed(lines []string) {
+	var panes []model.Window
+	for _, line := range lines {
+		if w, err := parsePaneLine(line); err == nil {
+			panes = append(panes, w)
+		}
+	}
+
+	c.mu.Lock()
+	if lines != nil {
+		c.panes = panes
+		c.synced = true
+	}
+	c.listing = false
+	again := c.relistNeeded
+	c.relistNeeded = false
+	c.mu.Unlock()
+
+	if again {
+		c.relist()
+	}
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
+
+// sendLocked writes a command and queues its reply handler; c.mu must be
+// held so handlers stay in command order.
+func (c *Control) sendLocked(command string, handle func([]string)) {
+	if _, err := io.WriteString(c.stdin, command+"\n"); err != nil {
+		return // client exited; Done says so
+	}
+	c.pending = append(c.pending, handle)
+}
+
+// reply passes a command's output to its handler; lines is nil on %error.
+func (c *Control) reply(lines []string, ok bool) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	handle := c.pending[0]
+	c.pending = c.pending[1:]
+	c.mu.Unlock()
+
+	if !ok {
+		lines = nil
+	} else if lines == nil {
+		lines = []string{} // success with no output
+	}
+	handle(lines)
+}
+
+// resync re-reads the table periodically until the client exits.
+func (c *Control) resync() {
+	t := time.NewTicker(resyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.relist()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+var (
+	versionOnce sync.Once
+	version     string
+)
+
+// versionAtLeast reports whether tmux is at least
+// major.minor. Builds without a release number (master, OS packages)
+// count as new.
+func versionAtLeast(major, minor int) bool {
+	versionOnce.Do(func() {
+		out, err := exec.Command("tmux", "-V").Output()
+		if err == nil {
+			version = strings.TrimPrefix(strings.TrimSpace(string(out)), "tmux ")
+		}
+	})
+	var ma, mi int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(version, "next-"), "%d.%d", &ma, &mi); err != nil {
+		return true
+	}
+	return ma > major || ma == major && mi >= minor
+}
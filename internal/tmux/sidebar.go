@@ -0,0 +1,276 @@
+package tmux
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// sidebarOption marks sidebar panes. Unlike the pane title, which the
+// shell or Claude can overwrite, a pane user option is only set by us.
+const sidebarOption = "@ctree-sidebar"
+
+// sidebarHookOption remembers which entry of the after-new-window hook
+// array is the auto-open hook, at the scope it was installed at, so the
+// user's own entries are left alone.
+const sidebarHookOption = "@ctree-sidebar-hook"
+
+// SidebarOptions describe how sidebars are opened.
+type SidebarOptions struct {
+	Width    int    // columns, or rows for top/bottom
+	Side     string // left, right, top, bottom
+	Position string // "window" spans the window edge, "pane" splits the active pane
+
+	// Command is the shell command run in each sidebar pane.
+	Command string
+
+	// AutoOpen, if set, is a shell command for tmux's after-new-window
+	// hook so new windows get a sidebar too. "#{window_id}" in it expands
+	// to the new window.
+	AutoOpen string
+}
+
+// SidebarPane is an open sidebar.
+type SidebarPane struct {
+	PaneID    string
+	WindowID  string
+	SessionID string
+}
+
+// ValidateSidebarOptions checks Side and Position.
+func ValidateSidebarOptions(opts SidebarOptions) error {
+	switch opts.Side {
+	case "left", "right", "top", "bottom":
+	default:
+		return fmt.Errorf("side must be left, right, top or bottom, not %q", opts.Side)
+	}
+	switch opts.Position {
+	case "window", "pane":
+	default:
+		return fmt.Errorf("position must be window or pane, not %q", opts.Position)
+	}
+	if opts.Width < 1 {
+		return fmt.Errorf("width must be positive")
+	}
+	return nil
+}
+
+// CurrentSession returns the ID of the session the calling client (or,
+// from run-shell, the most recent client) is attached to.
+func CurrentSession() (string, error) {
+	out, err := exec.Command("tmux", "display-message", "-p", "#{session_id}").Output()
+	if err != nil {
+		return "", fmt.Errorf("tmux display-message: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ListSidebars returns the open sidebars, in one session or, if
+// sessionID is "", everywhere.
+func ListSidebars(sessionID string) ([]SidebarPane, error) {
+	out, err := exec.Command("tmux", "list-panes", "-a",
+		"-F", "#{pane_id}\t#{window_id}\t#{session_id}\t#{"+sidebarOption+"}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("tmux list-panes: %w", err)
+	}
+
+	var panes []SidebarPane
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 || fields[3] == "" {
+			continue
+		}
+		if sessionID != "" && fields[2] != sessionID {
+			continue
+		}
+		panes = append(panes, SidebarPane{PaneID: fields[0], WindowID: fields[1], SessionID: fields[2]})
+	}
+	return panes, nil
+}
+
+// IsSidebarPane reports whether paneID is a ctree sidebar.
+func IsSidebarPane(paneID string) bool {
+	out, err := exec.Command("tmux", "display-message", "-p", "-t", paneID,
+		"#{"+sidebarOption+"}").Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// OpenSidebars opens a sidebar in every window of the session (all
+// sessions if sessionID is "") that doesn't have one, and installs the
+// auto-open hook at the same scope. Focus stays where it was.
+func OpenSidebars(sessionID string, opts SidebarOptions) error {
+	if err := ValidateSidebarOptions(opts); err != nil {
+		return err
+	}
+
+	open, err := ListSidebars(sessionID)
+	if err != nil {
+		return err
+	}
+	has := make(map[string]bool)
+	for _, p := range open {
+		has[p.WindowID] = true
+	}
+
+	args := []string{"list-windows", "-F", "#{window_id}"}
+	if sessionID == "" {
+		args = append(args, "-a")
+	} else {
+		args = append(args, "-t", sessionID)
+	}
+	out, err := exec.Command("tmux", args...).Output()
+	if err != nil {
+		return fmt.Errorf("tmux list-windows: %w", err)
+	}
+	for _, windowID := range strings.Fields(string(out)) {
+		if has[windowID] {
+			continue
+		}
+		has[windowID] = true // linked into several sessions
+		if err := OpenSidebar(windowID, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.AutoOpen == "" {
+		return nil
+	}
+	return installHook(sessionID, "run-shell "+quoteTmux(opts.AutoOpen))
+}
+
+// OpenSidebar opens a sidebar in one window unless it already has one.
+// The sidebar is split off without taking focus.
+func OpenSidebar(windowID string, opts SidebarOptions) error {
+	if err := ValidateSidebarOptions(opts); err != nil {
+		return err
+	}
+	open, err := ListSidebars("")
+	if err != nil {
+		return err
+	}
+	for _, p := range open {
+		if p.WindowID == windowID {
+			return nil
+		}
+	}
+
+	args := []string{"split-window", "-d", "-P", "-F", "#{pane_id}",
+		"-t", windowID, "-l", strconv.Itoa(opts.Width)}
+	switch opts.Side {
+	case "left":
+		args = append(args, "-h", "-b")
+	case "right":
+		args = append(args, "-h")
+	case "top":
+		args = append(args, "-v", "-b")
+	case "bottom":
+		args = append(args, "-v")
+	}
+	if opts.Position == "window" {
+		args = append(args, "-f")
+	}
+	args = append(args, opts.Command)
+
+	out, err := exec.Command("tmux", args...).Output()
+	if err != nil {
+		return fmt.Errorf("tmux split-window: %w", err)
+	}
+	paneID := strings.TrimSpace(string(out))
+	if err := exec.Command("tmux", "set-option", "-p", "-t", paneID, sidebarOption, "1").Run(); err != nil {
+		// Untagged, close and toggle would never find it
+		_ = exec.Command("tmux", "kill-pane", "-t", paneID).Run()
+		return fmt.Errorf("tmux set-option: %w", err)
+	}
+	return nil
+}
+
+// CloseSidebars closes the sidebars in one session (all sessions if
+// sessionID is "") and removes the auto-open hook at that scope.
+func CloseSidebars(sessionID string) error {
+	open, err := ListSidebars(sessionID)
+	if err != nil {
+		return err
+	}
+	for _, p := range open {
+		// Killing the pane hangs up the ctree inside it
+		_ = exec.Command("tmux", "kill-pane", "-t", p.PaneID).Run()
+	}
+	removeHook(sessionID)
+	return nil
+}
+
+// installHook sets the auto-open hook at the scope: in the entry it was
+// installed in before, else one already running the same command, else
+// the first free index.
+func installHook(sessionID, hook string) error {
+	out, err := exec.Command("tmux", append(scope("show-hooks", sessionID), "after-new-window")...).Output()
+	if err != nil {
+		return fmt.Errorf("tmux show-hooks: %w", err)
+	}
+	used := make(map[int]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		name, command, _ := strings.Cut(line, " ")
+		var i int
+		if _, err := fmt.Sscanf(name, "after-new-window[%d]", &i); err == nil {
+			used[i] = command
+		}
+	}
+
+	idx := -1
+	if i, ok := hookIndex(sessionID); ok {
+		idx = i
+	}
+	for i, command := range used {
+		if idx < 0 && command == hook {
+			idx = i
+		}
+	}
+	for i := 0; idx < 0; i++ {
+		if _, ok := used[i]; !ok {
+			idx = i
+		}
+	}
+
+	name := fmt.Sprintf("after-new-window[%d]", idx)
+	if err := exec.Command("tmux", append(scope("set-hook", sessionID), name, hook)...).Run(); err != nil {
+		return fmt.Errorf("tmux set-hook: %w", err)
+	}
+	return exec.Command("tmux", append(scope("set-option", sessionID), sidebarHookOption, strconv.Itoa(idx))...).Run()
+}
+
+// removeHook unsets the auto-open hook entry installHook recorded.
+func removeHook(sessionID string) {
+	idx, ok := hookIndex(sessionID)
+	if !ok {
+		return
+	}
+	name := fmt.Sprintf("after-new-window[%d]", idx)
+	_ = exec.Command("tmux", append(scope("set-hook", sessionID), "-u", name)...).Run()
+	_ = exec.Command("tmux", append(scope("set-option", sessionID), "-u", sidebarHookOption)...).Run()
+}
+
+// hookIndex reads the recorded auto-open hook index at the scope.
+func hookIndex(sessionID string) (int, bool) {
+	out, err := exec.Command("tmux", append(scope("show-options", sessionID), "-qv", sidebarHookOption)...).Output()
+	if err != nil {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	return idx, err == nil && idx >= 0
+}
+
+// scope returns arguments for a tmux command on a global or session
+// hook or option.
+func scope(command, sessionID string) []string {
+	if sessionID == "" {
+		return []string{command, "-g"}
+	}
+	return []string{command, "-t", sessionID}
+}
+
+// quoteTmux double-quotes s for tmux's command parser.
+func quoteTmux(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -1,7 +1,9 @@
 package tmux
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -11,7 +13,7 @@ import (
 )
 
 // Format string for list-panes. Fields separated by tab character.
-const paneFormat = "#{session_name}\t#{window_index}\t#{window_id}\t#{window_name}\t#{pane_id}\t#{pane_pid}\t#{pane_current_path}\t#{window_activity}\t#{window_active}"
+const paneFormat = "#{session_name}\t#{window_index}\t#{window_id}\t#{window_name}\t#{pane_id}\t#{pane_pid}\t#{pane_current_path}\t#{window_activity}\t#{window_active}\t#{pane_index}\t#{pane_active}"
 
 // ListAllPanes returns all tmux panes across all sessions.
 func ListAllPanes() ([]model.Window, error) {
@@ -36,13 +38,14 @@ func ListAllPanes() ([]model.Window, error) {
 
 func parsePaneLine(line string) (model.Window, error) {
 	fields := strings.Split(line, "\t")
-	if len(fields) < 9 {
-		return model.Window{}, fmt.Errorf("expected 9 fields, got %d", len(fields))
+	if len(fields) < 11 {
+		return model.Window{}, fmt.Errorf("expected 11 fields, got %d", len(fields))
 	}
 
 	windowIndex, _ := strconv.Atoi(fields[1])
 	panePID, _ := strconv.Atoi(fields[5])
 	activityEpoch, _ := strconv.ParseInt(fields[7], 10, 64)
+	paneIndex, _ := strconv.Atoi(fields[9])
 
 	return model.Window{
 		SessionName:    fields[0],
@@ -50,55 +53,148 @@ func parsePaneLine(line string) (model.Window, error) {
 		WindowID:       fields[2],
 		WindowName:     fields[3],
 		PaneID:         fields[4],
+		PaneIndex:      paneIndex,
 		PanePID:        panePID,
 		WorkingDir:     fields[6],
 		LastActivity:   time.Unix(activityEpoch, 0),
 		IsActiveWindow: fields[8] == "1",
+		IsActivePane:   fields[10] == "1",
 	}, nil
 }
 
-// SelectWindow switches tmux focus to the specified window,
-// then focuses the main (non-sidebar) pane so the user lands on Claude.
-func SelectWindow(sessionName string, windowIndex int) error {
-	target := fmt.Sprintf("%s:%d", sessionName, windowIndex)
-	if err := exec.Command("tmux", "select-window", "-t", target).Run(); err != nil {
+// SelectPane switches tmux focus to the window holding paneID, moving the
+// client to its session if needed, then focuses the pane itself so the
+// user lands on that agent rather than another pane of the window.
+func SelectPane(paneID string) error {
+	if err := exec.Command("tmux", "select-window", "-t", paneID).Run(); err != nil {
 		return err
 	}
+	// Fails harmlessly when not run from a client
+	_ = exec.Command("tmux", "switch-client", "-t", paneID).Run()
 
-	// Find the non-ctree pane and focus it
-	out, err := exec.Command("tmux", "list-panes", "-t", target,
-		"-F", "#{pane_id} #{pane_title}").Output()
+	// Pane focus is best-effort once the window is switched
+	_ = exec.Command("tmux", "select-pane", "-t", paneID).Run()
+	return nil
+}
+
+// WindowOptions configure NewAgentWindow. The zero value opens an
+// unnamed window in the current session and switches to it.
+type WindowOptions struct {
+	Name     string // window name; tmux names it after the command if empty
+	Dir      string // working directory
+	Session  string // tmux session to open the window in
+	Detached bool   // don't switch to the new window
+}
+
+// NewAgentWindow creates a new tmux window running command (an agent's
+// launch argv) and returns its pane ID.
+func NewAgentWindow(opts WindowOptions, command []string) (string, error) {
+	args := []string{"new-window", "-P", "-F", "#{pane_id}"}
+	if opts.Name != "" {
+		args = append(args, "-n", opts.Name)
+	}
+	if opts.Dir != "" {
+		args = append(args, "-c", opts.Dir)
+	}
+	if opts.Session != "" {
+		// The trailing colon picks the session's next free window index
+		args = append(args, "-t", opts.Session+":")
+	}
+	if opts.Detached {
+		args = append(args, "-d")
+	}
+	args = append(args, command...)
+	out, err := exec.Command("tmux", args...).Output()
 	if err != nil {
-		return nil // window switched, pane focus is best-effort
+		// Scripts need to see why, e.g. "can't find session: build"
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("tmux new-window: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("tmux new-window: %w", err)
 	}
+	return strings.TrimSpace(string(out)), nil
+}
 
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) < 2 {
-			continue
-		}
-		paneID := parts[0]
-		paneTitle := parts[1]
-		if paneTitle != "ctree-sidebar" {
-			_ = exec.Command("tmux", "select-pane", "-t", paneID).Run()
-			return nil
-		}
+// SendText types text into a pane and presses Enter, submitting it as a
+// prompt. Text is sent literally, without tmux key name lookup.
+func SendText(paneID, text string) error {
+	if err := exec.Command("tmux", "send-keys", "-t", paneID, "-l", "--", text).Run(); err != nil {
+		return fmt.Errorf("tmux send-keys: %w", err)
+	}
+	if err := exec.Command("tmux", "send-keys", "-t", paneID, "Enter").Run(); err != nil {
+		return fmt.Errorf("tmux send-keys: %w", err)
 	}
+	return nil
+}
 
+// SendKey presses a key in a pane, by tmux key name ("Escape").
+func SendKey(paneID, key string) error {
+	if err := exec.Command("tmux", "send-keys", "-t", paneID, key).Run(); err != nil {
+		return fmt.Errorf("tmux send-keys: %w", err)
+	}
 	return nil
 }
 
-// NewClaudeWindow creates a new tmux window and starts claude in it.
-func NewClaudeWindow(name, dir string) error {
-	args := []string{"new-window"}
-	if name != "" {
-		args = append(args, "-n", name)
+// KillWindow closes the window holding paneID, with every pane in it.
+func KillWindow(paneID string) error {
+	if err := exec.Command("tmux", "kill-window", "-t", paneID).Run(); err != nil {
+		return fmt.Errorf("tmux kill-window: %w", err)
 	}
+	return nil
+}
+
+// RespawnPane kills whatever runs in a pane and starts command in its
+// place, in dir. The pane keeps its ID and position. command runs through
+// the user's shell, which takes over once it exits, as if it had been
+// typed at the prompt: the pane doesn't close with it.
+func RespawnPane(paneID, dir string, command []string) error {
+	args := []string{"respawn-pane", "-k", "-t", paneID}
 	if dir != "" {
 		args = append(args, "-c", dir)
 	}
-	args = append(args, "claude")
-	return exec.Command("tmux", args...).Run()
+	// tmux runs a single string with its default-shell
+	args = append(args, shellJoin(command)+"; exec "+shellQuote(defaultShell()))
+	if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("tmux respawn-pane: %s", msg)
+		}
+		return fmt.Errorf("tmux respawn-pane: %w", err)
+	}
+	return nil
+}
+
+// defaultShell returns tmux's default-shell, the shell it starts panes in.
+func defaultShell() string {
+	out, err := exec.Command("tmux", "show-options", "-gv", "default-shell").Output()
+	if shell := strings.TrimSpace(string(out)); err == nil && shell != "" {
+		return shell
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// shellJoin quotes argv into one POSIX shell command line.
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote single-quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DisplayPopup runs command in a tmux popup over the current client and
+// waits for it to exit. width and height take tmux sizes ("80%", "100").
+func DisplayPopup(command, width, height string) error {
+	return exec.Command("tmux", "display-popup", "-E",
+		"-w", width, "-h", height, command).Run()
 }
 
 // CapturePaneVisible captures the visible pane content, trimming trailing empty lines.
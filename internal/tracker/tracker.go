@@ -0,0 +1,418 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gxespino/ctree/internal/detect"
+	"github.com/gxespino/ctree/internal/git"
+	"github.com/gxespino/ctree/internal/history"
+	"github.com/gxespino/ctree/internal/hookdata"
+	"github.com/gxespino/ctree/internal/model"
+	"github.com/gxespino/ctree/internal/state"
+	"github.com/gxespino/ctree/internal/tmux"
+)
+
+// doneTimeout is how long Done persists before decaying to Idle.
+// Short enough to not get stuck, long enough to be visible.
+const doneTimeout = 15 * time.Second
+
+// seenRetention is how long a closed pane's seen flag is kept.
+const seenRetention = 24 * time.Hour
+
+// Ended sessions are remembered for resuming: the most recent maxExited,
+// for up to exitedRetention.
+const (
+	maxExited       = 20
+	exitedRetention = 7 * 24 * time.Hour
+)
+
+// Tracker refines detected statuses into Unread / Done across polls.
+// Everything is keyed by pane ID, since a window can run several agents;
+// a new Claude session ID in a pane starts its history over.
+// It is not safe for concurrent use.
+type Tracker struct {
+	prevStatuses map[string]model.Status // paneID → last known status
+	doneAt       map[string]time.Time    // paneID → when session entered Done
+	sessions     map[string]string       // paneID → Claude session ID running in it
+	live         map[string]model.Window // paneID → session as of the last pass
+	messages     map[string]string       // paneID → the agent's last reply
+	state        *state.PersistentState
+}
+
+// Result reports what a Refine pass found beyond the refined statuses.
+type Result struct {
+	// Chime is set when a session just finished or started needing input.
+	Chime bool
+
+	// Transitions are the refined statuses (Unread, Done, decay to Idle)
+	// no hook reports, for the session history logs.
+	Transitions []hookdata.Event
+}
+
+// New creates a Tracker. It runs one detection pass so a freshly started
+// sidebar or daemon doesn't reset everything to Idle.
+func New(s *state.PersistentState) *Tracker {
+	prev := make(map[string]model.Status)
+	sessions := make(map[string]string)
+	live := make(map[string]model.Window)
+	windows, err := Detect()
+	if err == nil {
+		for _, w := range windows {
+			prev[w.PaneID] = w.Status
+			if w.SessionID != "" {
+				sessions[w.PaneID] = w.SessionID
+			}
+			if w.Status != model.StatusExited {
+				live[w.PaneID] = w
+			}
+		}
+	}
+
+	return &Tracker{
+		prevStatuses: prev,
+		doneAt:       make(map[string]time.Time),
+		sessions:     sessions,
+		live:         live,
+		messages:     make(map[string]string),
+		state:        s,
+	}
+}
+
+// MigrateState upgrades state saved in an older format, matching it to
+// today's panes, and saves it. Only the daemon, which owns state.json,
+// does this.
+func MigrateState(s *state.PersistentState) error {
+	if s.Version >= state.Version {
+		return nil
+	}
+	// Without a pane list every old entry would look closed; migrate on
+	// a later start instead
+	windows, err := Detect()
+	if err != nil {
+		return err
+	}
+	panesOf := func(target string) []string {
+		var ids []string
+		for _, w := range windows {
+			if w.Target() == target {
+				ids = append(ids, w.PaneID)
+			}
+		}
+		return ids
+	}
+	if s.Migrate(panesOf) {
+		return state.Save(s)
+	}
+	return nil
+}
+
+// Detect discovers tmux panes and returns those running Claude or another
+// agent, with hook-reported status.
+func Detect() ([]model.Window, error) {
+	allPanes, err := tmux.ListAllPanes()
+	if err != nil {
+		return nil, err
+	}
+	return DetectPanes(allPanes, nil), nil
+}
+
+// DetectPanes is Detect for an already listed pane table, e.g. one kept
+// by a tmux.Control. It modifies allPanes. CPU and memory are measured
+// only with a usage sampler.
+func DetectPanes(allPanes []model.Window, usage *detect.Usage) []model.Window {
+	detect.EnrichAll(allPanes, usage)
+
+	var result []model.Window
+	for _, w := range allPanes {
+		if w.IsClaudePane {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// CleanupHooks removes the hook status files of panes that have closed.
+// allPanes is every pane tmux lists, not only the agent ones.
+func CleanupHooks(allPanes []model.Window) {
+	open := make(map[string]bool, len(allPanes))
+	for _, w := range allPanes {
+		open[w.PaneID] = true
+	}
+	hookdata.Cleanup(open, time.Minute)
+}
+
+// FillGit attaches the repository, branch and diff stats to each window,
+// querying directories in parallel. The git package caches results.
+func FillGit(windows []model.Window) {
+	var wg sync.WaitGroup
+	for i := range windows {
+		if windows[i].WorkingDir == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(w *model.Window) {
+			defer wg.Done()
+			if repo, err := git.GetRepo(w.WorkingDir); err == nil {
+				w.GitRoot = repo.Toplevel
+				w.GitCommonDir = repo.CommonDir
+			}
+			branch, added, removed, dirty, err := git.GetStats(w.WorkingDir)
+			if err != nil {
+				return
+			}
+			w.GitBranch = branch
+			w.GitAdded = added
+			w.GitRemoved = removed
+			w.GitDirty = dirty
+		}(&windows[i])
+	}
+	wg.Wait()
+}
+
+// Sort groups sessions by repository, then worktree and directory
+// (stable sort preserves tmux order within groups). Directories outside a
+// repository sort among the repositories by their own path.
+func Sort(windows []model.Window) {
+	repo := func(w model.Window) string {
+		if w.GitCommonDir != "" {
+			return w.GitCommonDir
+		}
+		return w.WorkingDir
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		a, b := windows[i], windows[j]
+		if repo(a) != repo(b) {
+			return repo(a) < repo(b)
+		}
+		if a.GitRoot != b.GitRoot {
+			return a.GitRoot < b.GitRoot
+		}
+		return a.WorkingDir < b.WorkingDir
+	})
+}
+
+// MarkSeen records that the user jumped to this agent pane.
+func (t *Tracker) MarkSeen(paneID string) {
+	t.state.MarkSeen(paneID)
+}
+
+// Exited returns the recently ended sessions, most recent first.
+func (t *Tracker) Exited() []model.ExitedSession {
+	return append([]model.ExitedSession(nil), t.state.Exited...)
+}
+
+// forget drops everything known about a pane's previous session.
+func (t *Tracker) forget(paneID string) {
+	delete(t.prevStatuses, paneID)
+	delete(t.doneAt, paneID)
+	delete(t.messages, paneID)
+	delete(t.state.LastSeen, paneID)
+}
+
+// Save persists seen flags to disk.
+func (t *Tracker) Save() error {
+	return state.Save(t.state)
+}
+
+// Refine applies the Unread / Done state machine to freshly detected
+// windows in place.
+//
+// The detect layer returns Working or Idle. We refine Idle into
+// Unread / Done / Idle based on transitions:
+//
+//	Working → Idle  =  Unread  (just finished, user should review)
+//	Unread  + user views  =  Done
+//	Unread  + not viewed  =  stays Unread
+//	Done    → stays Done  (until Working again or doneTimeout → Idle)
+//
+// This avoids relying on window_activity timestamps which drift.
+func (t *Tracker) Refine(incoming []model.Window) Result {
+	// A pane is in view when its window is, unless another agent pane of
+	// that window has the focus. The sidebar or a shell having it doesn't
+	// hide the window's agent.
+	agentFocused := make(map[string]bool)
+	for _, w := range incoming {
+		if w.IsActivePane {
+			agentFocused[w.WindowID] = true
+		}
+	}
+	inView := func(w *model.Window) bool {
+		return w.IsActiveWindow && (w.IsActivePane || !agentFocused[w.WindowID])
+	}
+
+	for i := range incoming {
+		w := &incoming[i]
+
+		// A new Claude session in the pane (restart, /clear) starts over
+		if w.SessionID != "" {
+			if old, ok := t.sessions[w.PaneID]; ok && old != w.SessionID {
+				t.forget(w.PaneID)
+			}
+			t.sessions[w.PaneID] = w.SessionID
+		} else {
+			// The hook file is gone; the session is the one we last saw
+			w.SessionID = t.sessions[w.PaneID]
+		}
+
+		// Non-idle statuses pass through untouched.
+		// Paused = waiting for user input (permission, question).
+		if w.Status != model.StatusIdle {
+			delete(t.doneAt, w.PaneID)
+			continue
+		}
+
+		prev, hasPrev := t.prevStatuses[w.PaneID]
+
+		switch {
+		case hasPrev && (prev == model.StatusWorking || prev == model.StatusPaused):
+			// Just finished working/paused → mark Unread, clear "seen" flag
+			w.Status = model.StatusUnread
+			delete(t.state.LastSeen, w.PaneID)
+
+		case hasPrev && prev == model.StatusUnread:
+			// Was Unread — did the user look at it?
+			if inView(w) {
+				t.state.MarkSeen(w.PaneID)
+				w.Status = model.StatusDone
+				t.doneAt[w.PaneID] = time.Now()
+			} else if _, seen := t.state.LastSeen[w.PaneID]; seen {
+				// User jumped to it since last poll
+				w.Status = model.StatusDone
+				t.doneAt[w.PaneID] = time.Now()
+			} else {
+				w.Status = model.StatusUnread
+			}
+
+		case hasPrev && prev == model.StatusDone:
+			if inView(w) {
+				t.state.MarkSeen(w.PaneID)
+			}
+			if at, ok := t.doneAt[w.PaneID]; ok && time.Since(at) < doneTimeout {
+				w.Status = model.StatusDone
+			} else {
+				delete(t.doneAt, w.PaneID)
+			}
+			// else decays to Idle
+
+		default:
+			// First poll or was already Idle — stay Idle
+			if inView(w) {
+				t.state.MarkSeen(w.PaneID)
+			}
+		}
+	}
+
+	var res Result
+	for _, w := range incoming {
+		prev, ok := t.prevStatuses[w.PaneID]
+		if !ok {
+			continue
+		}
+
+		// Detect transitions that need user attention (chime notification):
+		// Working/Paused → Unread (just finished), Working → Paused (needs input mid-task)
+		if (prev == model.StatusWorking || prev == model.StatusPaused) && w.Status == model.StatusUnread {
+			res.Chime = true
+		}
+		if prev == model.StatusWorking && w.Status == model.StatusPaused {
+			res.Chime = true
+		}
+
+		// Log the statuses only we know about (Unread, Done and the decay
+		// back to Idle) so `ctree history` can show them.
+		if prev == w.Status {
+			continue
+		}
+		refined := w.Status == model.StatusUnread || w.Status == model.StatusDone
+		decayed := w.Status == model.StatusIdle && (prev == model.StatusUnread || prev == model.StatusDone)
+		if refined || decayed {
+			res.Transitions = append(res.Transitions, hookdata.Event{
+				Event:     history.SidebarEvent,
+				Status:    history.StatusName(w.Status),
+				Timestamp: time.Now(),
+				SessionID: w.SessionID,
+				PaneID:    w.PaneID,
+				CWD:       w.WorkingDir,
+			})
+		}
+	}
+
+	// Update previous statuses for next poll
+	open := make(map[string]bool, len(incoming))
+	for _, w := range incoming {
+		t.prevStatuses[w.PaneID] = w.Status
+		open[w.PaneID] = true
+	}
+
+	t.recordExits(incoming)
+
+	// Pane IDs aren't reused while the tmux server runs, so whatever is
+	// kept for closed panes would pile up
+	for paneID := range t.sessions {
+		if !open[paneID] {
+			delete(t.sessions, paneID)
+		}
+	}
+	for paneID := range t.prevStatuses {
+		if !open[paneID] {
+			delete(t.prevStatuses, paneID)
+			delete(t.doneAt, paneID)
+		}
+	}
+	for paneID, at := range t.state.LastSeen {
+		if !open[paneID] && time.Since(at) > seenRetention {
+			delete(t.state.LastSeen, paneID)
+		}
+	}
+
+	return res
+}
+
+// recordExits remembers the sessions that were running on the last pass
+// and no longer are, whether the agent quit or its pane was closed, and
+// forgets remembered sessions that are running again.
+func (t *Tracker) recordExits(incoming []model.Window) {
+	live := make(map[string]model.Window, len(incoming))
+	for _, w := range incoming {
+		if w.Status == model.StatusExited {
+			continue
+		}
+		live[w.PaneID] = w
+		if w.LastReply != "" {
+			t.messages[w.PaneID] = w.LastReply
+		}
+		if w.SessionID != "" {
+			t.state.ForgetExit(w.SessionID)
+		}
+	}
+
+	for paneID, w := range t.live {
+		if _, ok := live[paneID]; ok {
+			continue
+		}
+		// The hook file, and with it SessionID, may be gone by now
+		sessionID := w.SessionID
+		if sessionID == "" {
+			sessionID = t.sessions[paneID]
+		}
+		if sessionID != "" {
+			// Git stats are only filled in after refining; git.GetStats caches
+			branch, _, _, _, _ := git.GetStats(w.WorkingDir)
+			t.state.RecordExit(model.ExitedSession{
+				SessionID:  sessionID,
+				Agent:      w.Agent,
+				WorkingDir: w.WorkingDir,
+				GitBranch:  branch,
+				LastStatus: w.Status,
+				Message:    t.messages[paneID],
+				ExitedAt:   time.Now(),
+			})
+		}
+		delete(t.messages, paneID)
+	}
+	t.live = live
+
+	t.state.TrimExited(maxExited, exitedRetention)
+}
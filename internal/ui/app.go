@@ -2,50 +2,88 @@ package ui
 
 import (
 	"fmt"
-	"sort"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gxespino/ctree/internal/agent"
+	"github.com/gxespino/ctree/internal/daemon"
 	"github.com/gxespino/ctree/internal/detect"
 	"github.com/gxespino/ctree/internal/model"
 	"github.com/gxespino/ctree/internal/state"
-	"github.com/gxespino/ctree/internal/tmux"
+	"github.com/gxespino/ctree/internal/tracker"
 )
 
-
-// doneTimeout is how long Done persists before decaying to Idle.
-// Short enough to not get stuck, long enough to be visible.
-const doneTimeout = 15 * time.Second
-
 // App is the top-level Bubble Tea model.
 type App struct {
-	list         list.Model
-	windows      []model.Window
-	prevStatuses map[string]model.Status // windowID → last known status
-	doneAt       map[string]time.Time    // windowID → when session entered Done
-	width        int
-	height       int
-	keys         keyMap
-	state        *state.PersistentState
-	err          error
-	focused      bool
+	list    list.Model
+	windows []model.Window
+	tracker *tracker.Tracker // Unread/Done state machine, only while no daemon is running
+	width   int
+	height  int
+	keys    keyMap
+	err     error
+	focused bool
+
+	grouping  grouping
+	collapsed map[string]bool // tree headers folded with h, by treeItem key
+	marked    map[string]bool // sessions marked with space for a broadcast, by pane ID
+
+	exited []model.ExitedSession // recently ended sessions, the tree's last section
+
+	form   *newAgentForm // open while creating an agent with n
+	prompt *promptBox    // open while typing a prompt to send with i
+
+	broadcast []sendResult   // the last broadcast's results, shown until the next key
+	confirm   *confirmPrompt // asking before a destructive action
 
 	showPreview    bool
 	previewContent string
 	previewPaneID  string
-	bellEnabled    bool
-	slackEnabled   bool
+
+	showUsage *bool         // shared with the delegate
+	usage     *detect.Usage // CPU sampler for local polls
+
+	showTimeline    bool
+	timelineContent string
+	timelinePaneID  string
+	timelineAt      time.Time // when the timeline panel was last refreshed
+
+	bellEnabled  bool
+	slackEnabled bool
 
 	spinnerFrame *int
+	spinning     bool // a spinCmd loop is running
+
+	// sub streams snapshots from the ctree daemon. While connected, the
+	// daemon owns detection and state and this sidebar is a thin client.
+	sub *daemon.Subscription
+
+	connecting bool      // a connectDaemonCmd is in flight
+	connectAt  time.Time // when the last connection failed or dropped
+	ticking    bool      // the tick loop runs; started once the first connection attempt is done
+	polledAt   time.Time // when the last applied local poll started
+
+	opts Options
 }
 
-// NewApp creates a new App.
-func NewApp(s *state.PersistentState) App {
+// Options configure an App.
+type Options struct {
+	// OneShot quits after jumping to a session, for `ctree popup`.
+	OneShot bool
+}
+
+// NewApp creates a new App. It connects to the daemon first; state is
+// only loaded, and panes polled here, if there is none.
+func NewApp(opts Options) App {
 	frame := new(int)
-	delegate := newWindowDelegate(frame)
+	showUsage := new(bool)
+	*showUsage = state.GetUsage()
+	delegate := newWindowDelegate(frame, showUsage)
 	l := list.New([]list.Item{}, delegate, 40, 20)
 	l.Title = "CTree"
 	l.SetShowStatusBar(false)
@@ -55,34 +93,26 @@ func NewApp(s *state.PersistentState) App {
 	l.Styles.Title = headerStyle
 	l.SetStatusBarItemName("session", "sessions")
 
-	// Bootstrap: detect current statuses so reopening the sidebar
-	// doesn't reset everything to Idle.
-	prev := make(map[string]model.Status)
-	if panes, err := tmux.ListAllPanes(); err == nil {
-		detect.EnrichAll(panes)
-		for _, w := range panes {
-			if w.IsClaudePane {
-				prev[w.WindowID] = w.Status
-			}
-		}
-	}
-
 	return App{
 		list:         l,
 		keys:         defaultKeyMap(),
-		state:        s,
-		prevStatuses: prev,
-		doneAt:       make(map[string]time.Time),
+		grouping:     parseGrouping(state.GetGrouping()),
+		collapsed:    map[string]bool{exitedKey: true},
+		marked:       make(map[string]bool),
 		focused:      true,
 		showPreview:  state.GetPreview(),
+		showUsage:    showUsage,
+		usage:        &detect.Usage{},
 		bellEnabled:  state.GetBell(),
 		slackEnabled: state.GetSlack(),
 		spinnerFrame: frame,
+		connecting:   true, // by Init
+		opts:         opts,
 	}
 }
 
 func (a App) Init() tea.Cmd {
-	return tea.Batch(pollTmuxCmd(), tickCmd())
+	return connectDaemonCmd()
 }
 
 func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -97,24 +127,129 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, nil
 
 	case tickMsg:
-		return a, pollTmuxCmd()
+		a.syncToggles()
+		if a.sub != nil {
+			// The daemon pushes snapshots; just keep the timeline ticking
+			return a, tea.Batch(a.refreshTimelineCmd(false), tickCmd(subscribedTickInterval))
+		}
+		// The next tick is scheduled when this poll's result arrives, so
+		// slow polls never pile up
+		cmds := []tea.Cmd{a.pollCmd(true)}
+		if !a.connecting && time.Since(a.connectAt) >= reconnectDelay {
+			a.connecting = true
+			cmds = append(cmds, connectDaemonCmd())
+		}
+		return a, tea.Batch(cmds...)
+
+	case spinMsg:
+		*a.spinnerFrame++
+		if a.anyWorking() {
+			return a, spinCmd()
+		}
+		a.spinning = false
+		return a, nil
+
+	case daemonConnectedMsg:
+		a.connecting = false
+		if msg.err != nil {
+			// No daemon: poll locally, retrying on tick
+			a.connectAt = time.Now()
+			if !a.ticking {
+				a.ticking = true
+				return a, a.pollCmd(true)
+			}
+			return a, nil
+		}
+		a.sub = msg.sub
+		// The daemon owns state.json now; should it go away, a new local
+		// tracker reads what it saved
+		a.tracker = nil
+		cmds := []tea.Cmd{waitForSnapshotCmd(a.sub)}
+		if !a.ticking {
+			a.ticking = true
+			cmds = append(cmds, tickCmd(subscribedTickInterval))
+		}
+		return a, tea.Batch(cmds...)
+
+	case daemonSnapshotMsg:
+		if a.sub == nil {
+			return a, nil
+		}
+		a.err = nil
+		var cmd tea.Cmd
+		a, cmd = a.setWindows(msg.windows, msg.exited, msg.chime)
+		return a, tea.Batch(cmd, waitForSnapshotCmd(a.sub))
+
+	case daemonLostMsg:
+		a.sub = nil
+		a.connectAt = time.Now()
+		return a, nil
 
 	case pollResultMsg:
-		return a.handlePollResult(msg)
+		m, cmd := a.handlePollResult(msg)
+		if msg.tick {
+			interval := pollInterval
+			if a.sub != nil {
+				interval = subscribedTickInterval
+			}
+			cmd = tea.Batch(cmd, tickCmd(interval))
+		}
+		return m, cmd
 
 	case gitResultMsg:
 		return a.handleGitResult(msg)
 
 	case jumpedMsg:
-		a.state.MarkSeen(msg.windowID)
-		_ = state.Save(a.state)
-		return a, tickCmd()
+		if a.sub != nil {
+			if a.opts.OneShot {
+				return a, tea.Sequence(markSeenCmd(a.sub, msg.paneID), tea.Quit)
+			}
+			return a, markSeenCmd(a.sub, msg.paneID)
+		}
+		if a.tracker != nil {
+			a.tracker.MarkSeen(msg.paneID)
+			_ = a.tracker.Save()
+		}
+		if a.opts.OneShot {
+			return a, tea.Quit
+		}
+		return a, a.pollCmd(false)
+
+	case decisionResultMsg:
+		if msg.err != nil {
+			a.err = msg.err
+		}
+		return a, a.refreshCmd()
+
+	case broadcastResultMsg:
+		a.broadcast = msg.results
+		a.updateListSize()
+		return a, a.refreshCmd()
+
+	case actionResultMsg:
+		if msg.err != nil {
+			a.err = msg.err
+		}
+		return a, a.refreshCmd()
+
+	case sendResultMsg:
+		if msg.err != nil {
+			a.err = msg.err
+		}
+		return a, a.refreshCmd()
 
 	case newWorkspaceResultMsg:
 		if msg.err != nil {
 			a.err = msg.err
 		}
-		return a, pollTmuxCmd()
+		return a, a.refreshCmd()
+
+	case timelineResultMsg:
+		if msg.err != nil || msg.paneID != a.timelinePaneID {
+			return a, nil
+		}
+		a.timelineContent = msg.content
+		return a, nil
 
 	case previewResultMsg:
 		if msg.err != nil || msg.paneID != a.previewPaneID {
@@ -125,7 +260,7 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case errMsg:
 		a.err = msg.err
-		return a, tickCmd()
+		return a, nil
 
 	case tea.FocusMsg:
 		a.focused = true
@@ -136,12 +271,39 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, nil
 	}
 
+	if a.form != nil {
+		return a.updateForm(msg) // e.g. cursor blinks
+	}
+	if a.prompt != nil {
+		return a.updatePrompt(msg)
+	}
+
 	var cmd tea.Cmd
 	a.list, cmd = a.list.Update(msg)
 	return a, cmd
 }
 
 func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if a.form != nil {
+		return a.updateForm(msg)
+	}
+	if a.prompt != nil {
+		return a.updatePrompt(msg)
+	}
+	if a.confirm != nil {
+		c := a.confirm
+		a.confirm = nil
+		a.updateListSize()
+		if key.Matches(msg, confirmYes) {
+			return a, c.action
+		}
+		return a, nil
+	}
+	if a.broadcast != nil {
+		a.broadcast = nil
+		a.updateListSize()
+	}
+
 	// If the list is filtering, let it handle all keys
 	if a.list.FilterState() == list.Filtering {
 		var cmd tea.Cmd
@@ -149,26 +311,142 @@ func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return a, cmd
 	}
 
+	prev, _ := a.selectedWindow()
+
 	switch {
 	case key.Matches(msg, a.keys.Quit):
 		return a, tea.Quit
 
 	case key.Matches(msg, a.keys.Enter):
-		if item, ok := a.list.SelectedItem().(model.Window); ok {
-			return a, jumpToWindowCmd(item.SessionName, item.WindowIndex)
+		if item, ok := a.list.SelectedItem().(treeItem); ok && item.kind == nodeExited {
+			argv, err := resumeCommand(item.exited.Agent, item.exited.SessionID)
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			return a, reopenCmd(item.exited.WorkingDir, argv)
+		}
+		if w, ok := a.selectedWindow(); ok {
+			return a, jumpToPaneCmd(w)
 		}
 
+	case key.Matches(msg, a.keys.Collapse):
+		cmd := a.collapseSelected()
+		return a.followSelection(prev.PaneID, cmd)
+
+	case key.Matches(msg, a.keys.Expand):
+		cmd := a.expandSelected()
+		return a.followSelection(prev.PaneID, cmd)
+
 	case key.Matches(msg, a.keys.JumpUnread):
 		// Find the most recent session needing attention and jump to it
 		for _, w := range a.windows {
 			if w.Status == model.StatusPaused || w.Status == model.StatusUnread || w.Status == model.StatusDone {
-				return a, jumpToWindowCmd(w.SessionName, w.WindowIndex)
+				return a, jumpToPaneCmd(w)
 			}
 		}
 		return a, nil
 
+	case key.Matches(msg, a.keys.Allow):
+		return a, a.decideSelected("allow")
+
+	case key.Matches(msg, a.keys.Deny):
+		return a, a.decideSelected("deny")
+
+	case key.Matches(msg, a.keys.AskTerminal):
+		return a, a.decideSelected("ask")
+
 	case key.Matches(msg, a.keys.NewWorkspace):
-		return a, newWorkspaceCmd()
+		a.form = newNewAgentForm(a.defaultRepoDir(), a.width)
+		a.updateListSize()
+		return a, textinput.Blink
+
+	case key.Matches(msg, a.keys.Send):
+		if len(a.marked) > 0 {
+			a.prompt = newBroadcastBox(a.markedPanes(), a.width)
+			a.updateListSize()
+			return a, textinput.Blink
+		}
+		item, ok := a.list.SelectedItem().(treeItem)
+		if !ok || item.kind != nodeLeaf {
+			return a, nil
+		}
+		if !item.window.AcceptsInput() {
+			a.err = notAcceptingErr(item.window)
+			return a, nil
+		}
+		a.prompt = newPromptBox(item.window, a.width)
+		a.updateListSize()
+		return a, textinput.Blink
+
+	case key.Matches(msg, a.keys.Mark):
+		if item, ok := a.list.SelectedItem().(treeItem); ok {
+			if item.kind == nodeLeaf {
+				a.toggleMarks([]string{item.key})
+			} else {
+				a.toggleMarks(item.panes)
+			}
+			return a, a.setItems()
+		}
+		return a, nil
+
+	case key.Matches(msg, a.keys.MarkGroup):
+		a.toggleMarks(a.selectedGroupPanes())
+		return a, a.setItems()
+
+	case key.Matches(msg, a.keys.Interrupt):
+		w, ok := a.selectedLeaf()
+		if !ok {
+			return a, nil
+		}
+		if w.Status != model.StatusWorking {
+			a.err = fmt.Errorf("%s isn't working", w.Title())
+			return a, nil
+		}
+		return a, interruptCmd(w.PaneID)
+
+	case key.Matches(msg, a.keys.Kill):
+		w, ok := a.selectedLeaf()
+		if !ok || w.ClaudePID == 0 {
+			return a, nil
+		}
+		name := w.Agent
+		if name == "" {
+			name = "agent"
+		}
+		a.ask(fmt.Sprintf("Kill %s (pid %d) in %s?", name, w.ClaudePID, w.Title()), killCmd(w.ClaudePID))
+		return a, nil
+
+	case key.Matches(msg, a.keys.CloseWindow):
+		w, ok := a.selectedLeaf()
+		if !ok {
+			return a, nil
+		}
+		a.ask(fmt.Sprintf("Close window %s (%s)?", w.Target(), w.WindowName), closeWindowCmd(w.PaneID))
+		return a, nil
+
+	case key.Matches(msg, a.keys.Restart):
+		w, ok := a.selectedLeaf()
+		if !ok {
+			return a, nil
+		}
+		if w.SessionID == "" {
+			a.err = fmt.Errorf("%s has no session ID to resume", w.Title())
+			return a, nil
+		}
+		argv, err := resumeCommand(w.Agent, w.SessionID)
+		if err != nil {
+			a.err = err
+			return a, nil
+		}
+		a.ask(fmt.Sprintf("Restart %s, resuming %s?", w.Title(), shortID(w.SessionID)),
+			restartCmd(w.PaneID, w.WorkingDir, argv))
+		return a, nil
+
+	case key.Matches(msg, a.keys.Group):
+		a.grouping = a.grouping.next()
+		state.SetGrouping(string(a.grouping))
+		return a, a.setItems()
 
 	case key.Matches(msg, a.keys.ToggleBell):
 		a.bellEnabled = !a.bellEnabled
@@ -180,20 +458,44 @@ func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		state.SetSlack(a.slackEnabled)
 		return a, slackNotifyCmd(a.slackEnabled)
 
+	case key.Matches(msg, a.keys.Timeline):
+		a.showTimeline = !a.showTimeline
+		if a.showTimeline && a.showPreview {
+			// One panel at a time
+			a.showPreview = false
+			state.SetPreview(false)
+		}
+		a.updateListSize()
+		if a.showTimeline {
+			if w, ok := a.selectedWindow(); ok {
+				a.timelinePaneID = w.PaneID
+				a.timelineContent = ""
+				return a, timelineCmd(w)
+			}
+		}
+		return a, nil
+
+	case key.Matches(msg, a.keys.Usage):
+		*a.showUsage = !*a.showUsage
+		state.SetUsage(*a.showUsage)
+		a.updateListSize()
+		return a, nil
+
 	case key.Matches(msg, a.keys.Preview):
 		a.showPreview = !a.showPreview
+		a.showTimeline = false
 		state.SetPreview(a.showPreview)
 		a.updateListSize()
 		if a.showPreview {
-			if item, ok := a.list.SelectedItem().(model.Window); ok {
-				a.previewPaneID = item.PaneID
-				return a, capturePreviewCmd(item.PaneID, a.previewHeight(), a.width-4)
+			if w, ok := a.selectedWindow(); ok {
+				a.previewPaneID = w.PaneID
+				return a, capturePreviewCmd(w.PaneID, a.previewHeight(), a.width-4)
 			}
 		}
 		return a, nil
 
 	case key.Matches(msg, a.keys.Refresh):
-		return a, pollTmuxCmd()
+		return a, a.refreshCmd()
 
 	case key.Matches(msg, a.keys.Escape):
 		if a.list.FilterState() == list.FilterApplied {
@@ -204,147 +506,394 @@ func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 
 	// Delegate to bubbles list for j/k/arrow nav and / filtering
-	prevIndex := a.list.Index()
 	var cmd tea.Cmd
 	a.list, cmd = a.list.Update(msg)
+	return a.followSelection(prev.PaneID, cmd)
+}
+
+// followSelection reloads the open preview or timeline panel when the
+// selection moved to a different session than prevPaneID.
+func (a App) followSelection(prevPaneID string, cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	w, ok := a.selectedWindow()
+	if !ok || w.PaneID == prevPaneID {
+		return a, cmd
+	}
+
+	// If selection changed while the timeline is open, load the new session's
+	if a.showTimeline {
+		a.timelinePaneID = w.PaneID
+		a.timelineContent = ""
+		return a, tea.Batch(cmd, timelineCmd(w))
+	}
 
 	// If selection changed while preview is open, fetch new preview
-	if a.showPreview && a.list.Index() != prevIndex {
-		if item, ok := a.list.SelectedItem().(model.Window); ok {
-			a.previewPaneID = item.PaneID
-			a.previewContent = ""
-			return a, tea.Batch(cmd, capturePreviewCmd(item.PaneID, a.previewHeight(), a.width-4))
-		}
+	if a.showPreview {
+		a.previewPaneID = w.PaneID
+		a.previewContent = ""
+		return a, tea.Batch(cmd, capturePreviewCmd(w.PaneID, a.previewHeight(), a.width-4))
 	}
 
 	return a, cmd
 }
 
-func (a App) handlePollResult(msg pollResultMsg) (tea.Model, tea.Cmd) {
-	if msg.err != nil {
-		a.err = msg.err
-		return a, tickCmd()
+// selectedWindow returns the session under the cursor. A tree header
+// stands for its most urgent session; ended sessions have none.
+func (a App) selectedWindow() (model.Window, bool) {
+	item, ok := a.list.SelectedItem().(treeItem)
+	if !ok || item.window.PaneID == "" {
+		return model.Window{}, false
 	}
+	return item.window, true
+}
 
-	a.err = nil
-	*a.spinnerFrame++
+// collapseSelected folds the selected header, or moves the cursor up to
+// the parent of a session or an already folded header.
+func (a *App) collapseSelected() tea.Cmd {
+	item, ok := a.list.SelectedItem().(treeItem)
+	if !ok {
+		return nil
+	}
+	if item.isHeader() && !item.collapsed {
+		a.collapsed[item.key] = true
+		return a.setItems()
+	}
+	items := a.list.VisibleItems()
+	for i := a.list.Index() - 1; i >= 0; i-- {
+		if parent, ok := items[i].(treeItem); ok && parent.depth < item.depth {
+			a.list.Select(i)
+			break
+		}
+	}
+	return nil
+}
 
-	// Sync toggles from disk so all ctree instances stay in sync
-	if diskPreview := state.GetPreview(); diskPreview != a.showPreview {
-		a.showPreview = diskPreview
-		a.previewContent = ""
-		a.updateListSize()
+// expandSelected unfolds the selected header, or moves the cursor down to
+// the first child of an unfolded one.
+func (a *App) expandSelected() tea.Cmd {
+	item, ok := a.list.SelectedItem().(treeItem)
+	if !ok || !item.isHeader() {
+		return nil
 	}
-	a.bellEnabled = state.GetBell()
-	a.slackEnabled = state.GetSlack()
+	if item.collapsed {
+		delete(a.collapsed, item.key)
+		return a.setItems()
+	}
+	items := a.list.VisibleItems()
+	if next := a.list.Index() + 1; next < len(items) {
+		if child, ok := items[next].(treeItem); ok && child.depth > item.depth {
+			a.list.Select(next)
+		}
+	}
+	return nil
+}
 
-	incoming := msg.windows
+// setItems rebuilds the tree rows from a.windows, keeping the cursor on
+// the same node.
+func (a *App) setItems() tea.Cmd {
+	var selected string
+	if item, ok := a.list.SelectedItem().(treeItem); ok {
+		selected = item.key
+	}
+	cmd := a.list.SetItems(buildTree(a.windows, a.exited, a.grouping, a.collapsed, a.marked))
+	for i, it := range a.list.VisibleItems() {
+		if item, ok := it.(treeItem); ok && item.key == selected {
+			a.list.Select(i)
+			break
+		}
+	}
+	return cmd
+}
 
-	// State machine for idle sessions. The detect layer returns Working or Idle.
-	// We refine Idle into Unread / Done / Idle based on transitions:
-	//
-	//   Working → Idle  =  Unread  (just finished, user should review)
-	//   Unread  + user views  =  Done
-	//   Unread  + not viewed  =  stays Unread
-	//   Done    → stays Done  (until Working again or 5 min timeout → Idle)
-	//
-	// This avoids relying on window_activity timestamps which drift.
-	for i := range incoming {
-		w := &incoming[i]
+// updateForm handles a message while the new agent form is open.
+func (a App) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	submit, cancel, cmd := a.form.update(msg)
+	switch {
+	case cancel:
+		a.form = nil
+	case submit:
+		req := a.form.request()
+		a.form = nil
+		cmd = newWorkspaceCmd(req)
+	}
+	if a.form == nil {
+		a.updateListSize()
+	}
+	return a, cmd
+}
 
-		// Non-idle statuses pass through untouched.
-		// Paused = waiting for user input (permission, question).
-		if w.Status != model.StatusIdle {
-			delete(a.doneAt, w.WindowID)
-			continue
+// updatePrompt handles a message while the prompt box is open. The
+// session's status is checked again on submit, since it may have started
+// working while the prompt was being typed.
+func (a App) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
+	submit, cancel, cmd := a.prompt.update(msg)
+	switch {
+	case cancel:
+		a.prompt = nil
+	case submit:
+		p := a.prompt
+		a.prompt = nil
+		text := strings.TrimSpace(p.input.Value())
+		if text == "" {
+			break
+		}
+		if p.broadcast {
+			byPane := make(map[string]model.Window, len(a.windows))
+			for _, w := range a.windows {
+				byPane[w.PaneID] = w
+			}
+			var targets []model.Window
+			for _, paneID := range a.markedPanes() {
+				if w, ok := byPane[paneID]; ok {
+					targets = append(targets, w)
+				}
+			}
+			a.marked = make(map[string]bool)
+			cmd = tea.Batch(a.setItems(), broadcastCmd(targets, text))
+			break
+		}
+		w, ok := a.findPane(p.paneIDs[0])
+		switch {
+		case !ok:
+			a.err = fmt.Errorf("%s has exited", p.name)
+		case !w.AcceptsInput():
+			a.err = notAcceptingErr(w)
+		default:
+			cmd = sendCmd(w.PaneID, text)
+		}
+	}
+	if a.prompt == nil {
+		a.updateListSize()
+	}
+	return a, cmd
+}
+
+// toggleMarks marks the sessions in paneIDs for a broadcast, or unmarks
+// them if they all already are.
+func (a *App) toggleMarks(paneIDs []string) {
+	all := true
+	for _, id := range paneIDs {
+		all = all && a.marked[id]
+	}
+	for _, id := range paneIDs {
+		if all {
+			delete(a.marked, id)
+		} else {
+			a.marked[id] = true
 		}
+	}
+}
 
-		prev, hasPrev := a.prevStatuses[w.WindowID]
+// selectedGroupPanes returns the sessions in the selected header's group,
+// or in the group around the selected session: every session when the
+// tree is flat.
+func (a App) selectedGroupPanes() []string {
+	item, ok := a.list.SelectedItem().(treeItem)
+	if !ok {
+		return nil
+	}
+	if item.kind != nodeLeaf {
+		return item.panes
+	}
+	items := a.list.VisibleItems()
+	for i := a.list.Index() - 1; i >= 0; i-- {
+		if parent, ok := items[i].(treeItem); ok && parent.depth < item.depth {
+			return parent.panes
+		}
+	}
+	var all []string
+	for _, w := range a.windows {
+		all = append(all, w.PaneID)
+	}
+	return all
+}
 
+// markedPanes returns the marked sessions in tree order, including those
+// under collapsed headers.
+func (a App) markedPanes() []string {
+	var ids []string
+	for _, it := range a.list.Items() {
+		item, ok := it.(treeItem)
+		if !ok {
+			continue
+		}
 		switch {
-		case hasPrev && (prev == model.StatusWorking || prev == model.StatusPaused):
-			// Just finished working/paused → mark Unread, clear "seen" flag
-			w.Status = model.StatusUnread
-			delete(a.state.LastSeen, w.Target())
-
-		case hasPrev && prev == model.StatusUnread:
-			// Was Unread — did the user look at it?
-			if w.IsActiveWindow {
-				a.state.MarkSeen(w.Target())
-				w.Status = model.StatusDone
-				a.doneAt[w.WindowID] = time.Now()
-			} else if _, seen := a.state.LastSeen[w.Target()]; seen {
-				// User jumped to it since last poll
-				w.Status = model.StatusDone
-				a.doneAt[w.WindowID] = time.Now()
-			} else {
-				w.Status = model.StatusUnread
+		case item.kind == nodeLeaf:
+			if a.marked[item.key] {
+				ids = append(ids, item.key)
 			}
-
-		case hasPrev && prev == model.StatusDone:
-			if w.IsActiveWindow {
-				a.state.MarkSeen(w.Target())
+		case item.collapsed:
+			for _, paneID := range item.panes {
+				if a.marked[paneID] {
+					ids = append(ids, paneID)
+				}
 			}
-			if t, ok := a.doneAt[w.WindowID]; ok && time.Since(t) < doneTimeout {
-				w.Status = model.StatusDone
-			} else {
-				delete(a.doneAt, w.WindowID)
-			}
-			// else decays to Idle
+		}
+	}
+	return ids
+}
 
-		default:
-			// First poll or was already Idle — stay Idle
-			if w.IsActiveWindow {
-				a.state.MarkSeen(w.Target())
-			}
+// selectedLeaf returns the session under the cursor, unless the cursor
+// is on a tree header: actions on a single session don't guess.
+func (a App) selectedLeaf() (model.Window, bool) {
+	item, ok := a.list.SelectedItem().(treeItem)
+	if !ok || item.kind != nodeLeaf {
+		return model.Window{}, false
+	}
+	return item.window, true
+}
+
+// ask opens the confirm prompt for action.
+func (a *App) ask(question string, action tea.Cmd) {
+	a.confirm = &confirmPrompt{question: question, action: action}
+	a.updateListSize()
+}
+
+// resumeCommand is the argv that starts an agent back up in an earlier
+// conversation.
+func resumeCommand(agentName, sessionID string) ([]string, error) {
+	ag := agent.ByName(agentName)
+	if ag == nil {
+		ag = agent.Claude
+	}
+	return ag.LaunchCommand(agent.Launch{Resume: sessionID})
+}
+
+// sameExited reports whether two lists of ended sessions match.
+func sameExited(a, b []model.ExitedSession) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
+	return true
+}
 
-	// Persist state changes (seen flags, deletions) from the state machine above.
-	_ = state.Save(a.state)
+// shortID abbreviates a session ID for display.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
 
-	// Detect transitions that need user attention (chime notification)
-	shouldChime := false
-	for _, w := range incoming {
-		prev, ok := a.prevStatuses[w.WindowID]
-		if !ok {
-			continue
+// findPane returns the latest state of the session in paneID.
+func (a App) findPane(paneID string) (model.Window, bool) {
+	for _, w := range a.windows {
+		if w.PaneID == paneID {
+			return w, true
 		}
-		// Working/Paused → Unread (just finished)
-		if (prev == model.StatusWorking || prev == model.StatusPaused) && w.Status == model.StatusUnread {
-			shouldChime = true
+	}
+	return model.Window{}, false
+}
+
+// notAcceptingErr explains why a prompt can't be sent to w.
+func notAcceptingErr(w model.Window) error {
+	return fmt.Errorf("%s is %s; prompts can only be sent when it is idle, unread or done",
+		w.Title(), strings.ToLower(w.Status.String()))
+}
+
+// defaultRepoDir suggests the repository for a new agent: the selected
+// session's worktree, else ctree's own directory.
+func (a App) defaultRepoDir() string {
+	if w, ok := a.selectedWindow(); ok {
+		if w.GitRoot != "" {
+			return w.GitRoot
 		}
-		// Working → Paused (needs input mid-task)
-		if prev == model.StatusWorking && w.Status == model.StatusPaused {
-			shouldChime = true
+		if w.WorkingDir != "" {
+			return w.WorkingDir
 		}
 	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// decideSelected answers the selected session's pending permission request.
+// No-op unless its hook is waiting on the sidebar, or a header is selected.
+func (a App) decideSelected(decision string) tea.Cmd {
+	item, ok := a.list.SelectedItem().(treeItem)
+	if !ok || item.kind != nodeLeaf || !item.window.AwaitingDecision {
+		return nil
+	}
+	return decideCmd(item.window.PaneID, decision)
+}
 
-	// Update previous statuses for next poll
-	for _, w := range incoming {
-		a.prevStatuses[w.WindowID] = w.Status
+// handlePollResult runs the state machine over a local poll. Only used
+// when no daemon is running; otherwise the daemon does this for everyone.
+func (a App) handlePollResult(msg pollResultMsg) (tea.Model, tea.Cmd) {
+	if a.sub != nil {
+		return a, nil // a poll that raced the daemon connection
+	}
+	if msg.at.Before(a.polledAt) {
+		return a, nil // overtaken by a newer poll
+	}
+	a.polledAt = msg.at
+	if msg.err != nil {
+		a.err = msg.err
+		return a, nil
 	}
 
+	if a.tracker == nil {
+		if a.connecting {
+			return a, nil // a daemon may answer yet
+		}
+		// Load state only now: a daemon may have saved newer state
+		// since this sidebar started
+		s, err := state.Load()
+		if err != nil {
+			a.err = err
+			return a, nil
+		}
+		a.tracker = tracker.New(s)
+	}
+	a.err = nil
+
+	incoming := msg.windows
+	res := a.tracker.Refine(incoming)
+
+	// Persist state changes (seen flags, deletions) from the state machine.
+	_ = a.tracker.Save()
+
 	// Preserve git data from previous poll (git results arrive async)
 	for i := range incoming {
 		for j := range a.windows {
-			if incoming[i].WindowID == a.windows[j].WindowID {
+			if incoming[i].PaneID == a.windows[j].PaneID {
 				incoming[i].GitBranch = a.windows[j].GitBranch
 				incoming[i].GitAdded = a.windows[j].GitAdded
 				incoming[i].GitRemoved = a.windows[j].GitRemoved
 				incoming[i].GitDirty = a.windows[j].GitDirty
+				incoming[i].GitRoot = a.windows[j].GitRoot
+				incoming[i].GitCommonDir = a.windows[j].GitCommonDir
 				break
 			}
 		}
 	}
 
-	// Group sessions by project directory (stable sort preserves tmux order within groups)
-	sort.SliceStable(incoming, func(i, j int) bool {
-		return incoming[i].WorkingDir < incoming[j].WorkingDir
-	})
+	tracker.Sort(incoming)
+
+	a, cmd := a.setWindows(incoming, a.tracker.Exited(), res.Chime)
+	cmds := []tea.Cmd{cmd}
+	if len(res.Transitions) > 0 {
+		cmds = append(cmds, recordTransitionsCmd(res.Transitions))
+	}
+
+	// Fire git commands for each window
+	for _, w := range a.windows {
+		if w.WorkingDir != "" {
+			cmds = append(cmds, pollGitCmd(w.PaneID, w.WorkingDir))
+		}
+	}
+
+	return a, tea.Batch(cmds...)
+}
 
+// setWindows installs a refined session list, from a local poll or a
+// daemon snapshot, and refreshes everything that depends on it.
+func (a App) setWindows(incoming []model.Window, exited []model.ExitedSession, chime bool) (App, tea.Cmd) {
 	// Only update list items if something actually changed (prevents flash)
-	changed := len(incoming) != len(a.windows)
+	changed := len(incoming) != len(a.windows) || !sameExited(exited, a.exited)
 	if !changed {
 		for i := range incoming {
 			if windowFingerprint(incoming[i]) != windowFingerprint(a.windows[i]) {
@@ -355,47 +904,113 @@ func (a App) handlePollResult(msg pollResultMsg) (tea.Model, tea.Cmd) {
 	}
 
 	a.windows = incoming
+	a.exited = exited
+
+	// Forget marks on sessions that have gone
+	for id := range a.marked {
+		if _, ok := a.findPane(id); !ok {
+			delete(a.marked, id)
+		}
+	}
 
 	var cmds []tea.Cmd
-	if shouldChime && a.bellEnabled {
+	if chime && a.bellEnabled {
 		cmds = append(cmds, bellCmd())
 	}
 	if changed {
-		items := make([]list.Item, len(a.windows))
-		for i, w := range a.windows {
-			items[i] = w
-		}
-		cmd := a.list.SetItems(items)
-		if cmd != nil {
+		if cmd := a.setItems(); cmd != nil {
 			cmds = append(cmds, cmd)
 		}
 	}
 
-	// Fire git commands for each window
-	for _, w := range a.windows {
-		if w.WorkingDir != "" {
-			cmds = append(cmds, pollGitCmd(w.WindowID, w.WorkingDir))
-		}
-	}
-
 	// Refresh preview if open
 	if a.showPreview {
-		if item, ok := a.list.SelectedItem().(model.Window); ok {
-			a.previewPaneID = item.PaneID
-			cmds = append(cmds, capturePreviewCmd(item.PaneID, a.previewHeight(), a.width-4))
+		if w, ok := a.selectedWindow(); ok {
+			a.previewPaneID = w.PaneID
+			cmds = append(cmds, capturePreviewCmd(w.PaneID, a.previewHeight(), a.width-4))
 		}
 	}
 
-	cmds = append(cmds, tickCmd())
+	cmds = append(cmds, a.refreshTimelineCmd(changed))
+
+	// Animate the Working spinner independently of the poll rate
+	if !a.spinning && a.anyWorking() {
+		a.spinning = true
+		cmds = append(cmds, spinCmd())
+	}
 
 	return a, tea.Batch(cmds...)
 }
 
+// refreshTimelineCmd reloads the open timeline when statuses changed, and
+// about once a second so the ongoing span's duration keeps counting.
+func (a *App) refreshTimelineCmd(changed bool) tea.Cmd {
+	if !a.showTimeline || (!changed && time.Since(a.timelineAt) < time.Second) {
+		return nil
+	}
+	w, ok := a.selectedWindow()
+	if !ok {
+		return nil
+	}
+	a.timelinePaneID = w.PaneID
+	a.timelineAt = time.Now()
+	return timelineCmd(w)
+}
+
+// syncToggles reads toggles from disk so all ctree instances stay in sync.
+func (a *App) syncToggles() {
+	if diskPreview := state.GetPreview(); diskPreview != a.showPreview {
+		a.showPreview = diskPreview
+		a.previewContent = ""
+		if diskPreview {
+			a.showTimeline = false
+		}
+		a.updateListSize()
+	}
+	if diskUsage := state.GetUsage(); diskUsage != *a.showUsage {
+		*a.showUsage = diskUsage
+		a.updateListSize()
+	}
+	if diskGrouping := parseGrouping(state.GetGrouping()); diskGrouping != a.grouping {
+		a.grouping = diskGrouping
+		a.setItems()
+	}
+	a.bellEnabled = state.GetBell()
+	a.slackEnabled = state.GetSlack()
+}
+
+// refreshCmd asks for fresh data: from the daemon when connected, else a local poll.
+func (a App) refreshCmd() tea.Cmd {
+	if a.sub != nil {
+		return refreshDaemonCmd(a.sub)
+	}
+	return a.pollCmd(false)
+}
+
+// pollCmd polls locally, measuring CPU and memory only while shown.
+func (a App) pollCmd(tick bool) tea.Cmd {
+	if *a.showUsage {
+		return pollTmuxCmd(tick, a.usage)
+	}
+	return pollTmuxCmd(tick, nil)
+}
+
+// anyWorking reports whether any session shows the Working spinner.
+func (a App) anyWorking() bool {
+	for _, w := range a.windows {
+		if w.Status == model.StatusWorking {
+			return true
+		}
+	}
+	return false
+}
+
 // windowFingerprint creates a comparable string for change detection.
 func windowFingerprint(w model.Window) string {
-	return fmt.Sprintf("%s:%d:%s:%d:%s:%d:%d",
-		w.SessionName, w.WindowIndex, w.Status,
-		w.LastActivity.Unix(), w.GitBranch, w.GitAdded, w.GitRemoved)
+	return fmt.Sprintf("%s:%d:%s:%s:%t:%t:%s:%d:%s:%s:%d:%d:%.0f:%s",
+		w.SessionName, w.WindowIndex, w.PaneID, w.Status, w.StatusInferred, w.AwaitingDecision, w.Request(),
+		w.LastActivity.Unix(), w.GitCommonDir, w.GitBranch, w.GitAdded, w.GitRemoved,
+		w.CPUPercent, formatBytes(w.MemoryRSS))
 }
 
 func (a App) handleGitResult(msg gitResultMsg) (tea.Model, tea.Cmd) {
@@ -403,17 +1018,21 @@ func (a App) handleGitResult(msg gitResultMsg) (tea.Model, tea.Cmd) {
 		return a, nil
 	}
 
-	// Find and update the matching window, only if data changed
+	// Find and update the matching pane, only if data changed
 	changed := false
 	for i := range a.windows {
-		if a.windows[i].WindowID == msg.windowID {
+		if a.windows[i].PaneID == msg.paneID {
 			if a.windows[i].GitBranch != msg.branch ||
 				a.windows[i].GitAdded != msg.added ||
-				a.windows[i].GitRemoved != msg.removed {
+				a.windows[i].GitRemoved != msg.removed ||
+				a.windows[i].GitRoot != msg.root ||
+				a.windows[i].GitCommonDir != msg.commonDir {
 				a.windows[i].GitBranch = msg.branch
 				a.windows[i].GitAdded = msg.added
 				a.windows[i].GitRemoved = msg.removed
 				a.windows[i].GitDirty = msg.dirty
+				a.windows[i].GitRoot = msg.root
+				a.windows[i].GitCommonDir = msg.commonDir
 				changed = true
 			}
 			break
@@ -424,29 +1043,47 @@ func (a App) handleGitResult(msg gitResultMsg) (tea.Model, tea.Cmd) {
 		return a, nil
 	}
 
-	items := make([]list.Item, len(a.windows))
-	for i, w := range a.windows {
-		items[i] = w
-	}
-	cmd := a.list.SetItems(items)
+	cmd := a.setItems()
 	return a, cmd
 }
 
-// previewHeight returns how many lines the preview panel content area gets.
+// panelOpen reports whether the preview or timeline panel is shown.
+func (a App) panelOpen() bool {
+	return a.showPreview || a.showTimeline
+}
+
+// chromeHeight is the space outside the list and panel:
+// border(2) + title(2) + footer (1 blank + binding rows), plus the
+// prompt box's divider and input line while it is open, or a
+// broadcast's results.
+func (a App) chromeHeight() int {
+	h := 4 + 1 + len(a.footerRows())
+	if a.prompt != nil {
+		h += 2
+	}
+	if a.broadcast != nil {
+		h += 1 + len(a.broadcast)
+	}
+	if a.confirm != nil {
+		h += 2
+	}
+	return h
+}
+
+// previewHeight returns how many lines the preview or timeline panel content area gets.
 func (a App) previewHeight() int {
-	// border(2) + footer(5) + preview header(1) + title(2) = 10 lines overhead
-	available := a.height - 10
+	// chrome + panel header(1)
+	available := a.height - a.chromeHeight() - 1
 	if available < 4 {
 		return 4
 	}
 	return available / 2
 }
 
-// updateListSize recalculates the list dimensions based on whether preview is shown.
-// Footer is 5 lines (1 blank + 4 binding rows). Border is 2. Total overhead = 9.
+// updateListSize recalculates the list dimensions based on whether a panel is shown.
 func (a *App) updateListSize() {
-	const overhead = 9 // border(2) + title(2) + footer(5)
-	if a.showPreview {
+	overhead := a.chromeHeight()
+	if a.panelOpen() {
 		listHeight := a.height - overhead - a.previewHeight() - 1
 		if listHeight < 4 {
 			listHeight = 4
@@ -459,6 +1096,12 @@ func (a *App) updateListSize() {
 
 func (a App) View() string {
 	var b strings.Builder
+	if a.form != nil {
+		b.WriteString(a.form.view())
+		b.WriteString(a.renderFooter())
+		return a.frame(b.String())
+	}
+
 	b.WriteString(a.list.View())
 	b.WriteString("\n")
 
@@ -467,14 +1110,22 @@ func (a App) View() string {
 		b.WriteString("\n")
 	}
 
-	if a.showPreview {
-		// Divider line with label
-		divider := previewHeaderStyle.Render("── Preview ")
-		pad := a.width - 4 - len("── Preview ")
-		if pad > 0 {
-			divider += previewHeaderStyle.Render(strings.Repeat("─", pad))
+	if a.showTimeline {
+		b.WriteString(a.panelDivider("Timeline"))
+		b.WriteString("\n")
+
+		// Most recent transitions last, truncated to fit
+		lines := strings.Split(a.timelineContent, "\n")
+		maxLines := a.previewHeight()
+		if len(lines) > maxLines {
+			lines = lines[len(lines)-maxLines:]
+		}
+		for _, line := range lines {
+			b.WriteString(previewContentStyle.Render(line))
+			b.WriteString("\n")
 		}
-		b.WriteString(divider)
+	} else if a.showPreview {
+		b.WriteString(a.panelDivider("Preview"))
 		b.WriteString("\n")
 
 		// Preview content, truncated to fit
@@ -490,20 +1141,72 @@ func (a App) View() string {
 
 	}
 
+	if a.broadcast != nil {
+		summary, lines := broadcastReport(a.broadcast)
+		b.WriteString(a.panelDivider(summary))
+		b.WriteString("\n")
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	if a.confirm != nil {
+		b.WriteString(a.panelDivider("Confirm"))
+		b.WriteString("\n")
+		b.WriteString(a.confirm.view(a.width))
+		b.WriteString("\n")
+	}
+
+	if a.prompt != nil {
+		b.WriteString(a.panelDivider("Send to " + a.prompt.name))
+		b.WriteString("\n")
+		b.WriteString(a.prompt.view())
+		b.WriteString("\n")
+	}
+
 	b.WriteString(a.renderFooter())
 
-	content := b.String()
+	return a.frame(b.String())
+}
 
+// frame draws the border around the whole view, dimmed without focus.
+func (a App) frame(content string) string {
 	if a.focused {
 		return borderStyle.Width(a.width - 2).Height(a.height - 2).Render(content)
 	}
 	return borderDimStyle.Width(a.width - 2).Height(a.height - 2).Render(content)
 }
 
-// renderFooter builds the 2-column keybindings legend.
-func (a App) renderFooter() string {
-	key := func(k string) string { return footerKeyStyle.Render(k) }
-	desc := func(d string) string { return footerDescStyle.Render(d) }
+// panelDivider renders the labelled rule above the preview or timeline panel.
+func (a App) panelDivider(label string) string {
+	head := "── " + label + " "
+	divider := previewHeaderStyle.Render(head)
+	pad := a.width - 4 - len(head)
+	if pad > 0 {
+		divider += previewHeaderStyle.Render(strings.Repeat("─", pad))
+	}
+	return divider
+}
+
+// footerRows lists the keybinding legend as (key, desc, key, desc) rows.
+func (a App) footerRows() [][4]string {
+	if a.form != nil {
+		return [][4]string{
+			{"tab", "next field", "enter", "next/create"},
+			{"esc", "cancel", "", ""},
+		}
+	}
+	if a.prompt != nil {
+		return [][4]string{
+			{"enter", "send", "esc", "cancel"},
+		}
+	}
+	if a.confirm != nil {
+		return [][4]string{
+			{"y", "confirm", "esc", "cancel"},
+		}
+	}
 
 	previewLabel := "preview"
 	if a.showPreview {
@@ -518,6 +1221,40 @@ func (a App) renderFooter() string {
 		slackLabel = "slack on"
 	}
 
+	timelineLabel := "timeline"
+	if a.showTimeline {
+		timelineLabel = "close"
+	}
+
+	sendLabel := "send"
+	if n := len(a.marked); n > 0 {
+		sendLabel = fmt.Sprintf("send to %d", n)
+	}
+
+	usageLabel := "cpu/mem"
+	if *a.showUsage {
+		usageLabel = "hide usage"
+	}
+
+	return [][4]string{
+		{"j/k", "navigate", "tab", "unread"},
+		{"enter", "jump", "p", previewLabel},
+		{"t", timelineLabel, "u", usageLabel},
+		{"m", bellLabel, "s", slackLabel},
+		{"n", "new", "r", "refresh"},
+		{"h/l", "fold", "g", "by " + string(a.grouping)},
+		{"spc", "mark", "*", "mark group"},
+		{"x", "interrupt", "R", "restart"},
+		{"K", "kill", "W", "close window"},
+		{"i", sendLabel, "q", "quit"},
+	}
+}
+
+// renderFooter builds the 2-column keybindings legend.
+func (a App) renderFooter() string {
+	key := func(k string) string { return footerKeyStyle.Render(k) }
+	desc := func(d string) string { return footerDescStyle.Render(d) }
+
 	// Each row: left binding (padded to colWidth) + right binding
 	colWidth := 18
 	row := func(lk, ld, rk, rd string) string {
@@ -527,19 +1264,18 @@ func (a App) renderFooter() string {
 		if pad > 0 {
 			left += strings.Repeat(" ", pad)
 		}
+		if rk == "" {
+			return " " + left
+		}
 		right := key(rk) + desc(" "+rd)
 		return " " + left + right
 	}
 
 	var sb strings.Builder
-	sb.WriteString("\n")
-	sb.WriteString(row("j/k", "navigate", "tab", "unread"))
-	sb.WriteString("\n")
-	sb.WriteString(row("enter", "jump", "p", previewLabel))
-	sb.WriteString("\n")
-	sb.WriteString(row("m", bellLabel, "s", slackLabel))
-	sb.WriteString("\n")
-	sb.WriteString(row("n", "new", "q", "quit"))
+	for _, r := range a.footerRows() {
+		sb.WriteString("\n")
+		sb.WriteString(row(r[0], r[1], r[2], r[3]))
+	}
 
 	return sb.String()
 }
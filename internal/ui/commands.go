@@ -3,21 +3,41 @@ package ui
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gxespino/ctree/internal/agent"
+	"github.com/gxespino/ctree/internal/config"
+	"github.com/gxespino/ctree/internal/daemon"
 	"github.com/gxespino/ctree/internal/detect"
 	"github.com/gxespino/ctree/internal/git"
+	"github.com/gxespino/ctree/internal/history"
 	"github.com/gxespino/ctree/internal/hookdata"
 	"github.com/gxespino/ctree/internal/model"
 	"github.com/gxespino/ctree/internal/slack"
+	"github.com/gxespino/ctree/internal/state"
 	"github.com/gxespino/ctree/internal/tmux"
+	"github.com/gxespino/ctree/internal/tracker"
 )
 
 var pollCount int
 
-const pollInterval = 250 * time.Millisecond
+const (
+	pollInterval = 250 * time.Millisecond
+
+	// subscribedTickInterval is the tick while the daemon pushes snapshots.
+	// Nothing is polled; it only syncs toggles and the timeline panel.
+	subscribedTickInterval = time.Second
+
+	// spinInterval is the Working spinner's frame rate.
+	spinInterval = 250 * time.Millisecond
+
+	// reconnectDelay is how long to wait before reconnecting to a lost daemon.
+	reconnectDelay = 5 * time.Second
+)
 
 // capturePreviewCmd fetches visible pane content for the preview panel.
 func capturePreviewCmd(paneID string, maxLines, maxWidth int) tea.Cmd {
@@ -48,76 +68,264 @@ func capturePreviewCmd(paneID string, maxLines, maxWidth int) tea.Cmd {
 	}
 }
 
-// tickCmd schedules the next poll after a delay.
-func tickCmd() tea.Cmd {
-	return tea.Tick(pollInterval, func(t time.Time) tea.Msg {
+// timelineLookback is how far back the timeline panel reads a session's history.
+const timelineLookback = 24 * time.Hour
+
+// timelineCmd loads a session's status transitions for the timeline panel.
+func timelineCmd(w model.Window) tea.Cmd {
+	return func() tea.Msg {
+		id := hookdata.HistoryKey(w.SessionID, w.PaneID)
+		events, err := hookdata.ReadHistory(id, time.Now().Add(-timelineLookback))
+		if err != nil {
+			return timelineResultMsg{paneID: w.PaneID, err: err}
+		}
+
+		spans := history.Timeline(events)
+		if len(spans) == 0 {
+			return timelineResultMsg{paneID: w.PaneID, content: "No history yet"}
+		}
+
+		lines := make([]string, 0, len(spans)+1)
+		for _, s := range spans {
+			lines = append(lines, history.FormatSpan(s))
+		}
+		lines = append(lines, history.Summary(spans))
+
+		return timelineResultMsg{
+			paneID:  w.PaneID,
+			content: strings.Join(lines, "\n"),
+		}
+	}
+}
+
+// recordTransitionsCmd appends sidebar-refined statuses to the session history logs.
+func recordTransitionsCmd(events []hookdata.Event) tea.Cmd {
+	return func() tea.Msg {
+		for _, ev := range events {
+			_ = hookdata.AppendEvent(ev)
+		}
+		return nil
+	}
+}
+
+// tickCmd schedules the next tick after a delay.
+func tickCmd(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(t time.Time) tea.Msg {
 		return tickMsg(t)
 	})
 }
 
+// spinCmd schedules the next spinner frame.
+func spinCmd() tea.Cmd {
+	return tea.Tick(spinInterval, func(time.Time) tea.Msg {
+		return spinMsg{}
+	})
+}
+
+// connectDaemonCmd subscribes to the ctree daemon, starting one if needed.
+func connectDaemonCmd() tea.Cmd {
+	return func() tea.Msg {
+		if err := daemon.Start(); err != nil {
+			return daemonConnectedMsg{err: err}
+		}
+		sub, err := daemon.Subscribe()
+		return daemonConnectedMsg{sub: sub, err: err}
+	}
+}
+
+// waitForSnapshotCmd blocks until the daemon pushes the next snapshot.
+func waitForSnapshotCmd(sub *daemon.Subscription) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := sub.Next()
+		if err != nil {
+			sub.Close()
+			return daemonLostMsg{}
+		}
+		return daemonSnapshotMsg{windows: msg.Windows, exited: msg.Exited, chime: msg.Chime}
+	}
+}
+
+// markSeenCmd tells the daemon the user jumped to an agent pane.
+func markSeenCmd(sub *daemon.Subscription, paneID string) tea.Cmd {
+	return func() tea.Msg {
+		_ = sub.MarkSeen(paneID)
+		return nil
+	}
+}
+
+// refreshDaemonCmd asks the daemon to poll now.
+func refreshDaemonCmd(sub *daemon.Subscription) tea.Cmd {
+	return func() tea.Msg {
+		_ = sub.Refresh()
+		return nil
+	}
+}
+
 // pollTmuxCmd discovers tmux panes and enriches them with Claude status.
-func pollTmuxCmd() tea.Cmd {
+// Only used when no daemon is running. tick marks the tick loop's poll;
+// usage, if non-nil, measures CPU and memory.
+func pollTmuxCmd(tick bool, usage *detect.Usage) tea.Cmd {
 	return func() tea.Msg {
-		// Periodically clean up stale hook status files (~every 15s)
+		at := time.Now()
 		pollCount++
-		if pollCount%10 == 0 {
-			hookdata.Cleanup(5 * time.Minute)
+		// History logs grow slowly; prune them about once a minute
+		if pollCount%240 == 0 {
+			hookdata.PruneHistory()
 		}
 
-		allPanes, err := tmux.ListAllPanes()
+		// Let PermissionRequest hooks know a sidebar can answer them
+		state.TouchSidebar()
+
+		panes, err := tmux.ListAllPanes()
 		if err != nil {
-			return errMsg{err}
+			return pollResultMsg{err: err, at: at, tick: tick}
 		}
 
-		// Single-pass detection: prefers hook status, falls back to pane scraping
-		detect.EnrichAll(allPanes)
-
-		// Filter to only Claude panes
-		var result []model.Window
-		for _, w := range allPanes {
-			if w.IsClaudePane {
-				result = append(result, w)
-			}
+		// Periodically clean up status files of closed panes (~every 2.5s)
+		if pollCount%10 == 0 {
+			tracker.CleanupHooks(panes)
 		}
 
-		return pollResultMsg{windows: result}
+		return pollResultMsg{windows: tracker.DetectPanes(panes, usage), at: at, tick: tick}
 	}
 }
 
-// pollGitCmd fetches git metadata for a single window's working directory.
-func pollGitCmd(windowID, workingDir string) tea.Cmd {
+// pollGitCmd fetches git metadata for a single pane's working directory.
+func pollGitCmd(paneID, workingDir string) tea.Cmd {
 	return func() tea.Msg {
+		repo, _ := git.GetRepo(workingDir)
 		branch, added, removed, dirty, err := git.GetStats(workingDir)
 		return gitResultMsg{
-			windowID: windowID,
-			branch:   branch,
-			added:    added,
-			removed:  removed,
-			dirty:    dirty,
-			err:      err,
+			paneID:    paneID,
+			branch:    branch,
+			added:     added,
+			removed:   removed,
+			dirty:     dirty,
+			root:      repo.Toplevel,
+			commonDir: repo.CommonDir,
+			err:       err,
 		}
 	}
 }
 
-// jumpToWindowCmd switches tmux focus to the given window.
-func jumpToWindowCmd(sessionName string, windowIndex int) tea.Cmd {
+// jumpToPaneCmd switches tmux focus to the given session's pane.
+func jumpToPaneCmd(w model.Window) tea.Cmd {
 	return func() tea.Msg {
-		err := tmux.SelectWindow(sessionName, windowIndex)
+		err := tmux.SelectPane(w.PaneID)
 		if err != nil {
 			return errMsg{err}
 		}
-		return jumpedMsg{windowID: fmt.Sprintf("%s:%d", sessionName, windowIndex)}
+		return jumpedMsg{paneID: w.PaneID}
 	}
 }
 
-// newWorkspaceCmd creates a new tmux window running claude.
-func newWorkspaceCmd() tea.Cmd {
+// newWorkspaceCmd starts the default agent in a new tmux window named
+// after req.branch, in a new git worktree of req.repoDir checked out on
+// that branch. Without a branch it runs in req.repoDir itself.
+func newWorkspaceCmd(req newAgentRequest) tea.Cmd {
 	return func() tea.Msg {
-		err := tmux.NewClaudeWindow("", "")
+		argv, err := agent.Default().LaunchCommand(agent.Launch{Prompt: req.prompt})
+		if err != nil {
+			return newWorkspaceResultMsg{err: err}
+		}
+		dir := config.ExpandHome(req.repoDir)
+		if req.branch == "" {
+			_, err := tmux.NewAgentWindow(tmux.WindowOptions{Dir: dir}, argv)
+			return newWorkspaceResultMsg{err: err}
+		}
+
+		repo, err := git.GetRepo(dir)
+		if err != nil {
+			return newWorkspaceResultMsg{err: fmt.Errorf("%s is not a git repository", req.repoDir)}
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return newWorkspaceResultMsg{err: fmt.Errorf("%s: %w", config.Path(), err)}
+		}
+		path := filepath.Join(cfg.Worktrees.Root, git.RepoName(repo.CommonDir), req.branch)
+		if err := git.AddWorktree(dir, path, req.branch, req.base); err != nil {
+			return newWorkspaceResultMsg{err: err}
+		}
+		_, err = tmux.NewAgentWindow(tmux.WindowOptions{Name: req.branch, Dir: path}, argv)
 		return newWorkspaceResultMsg{err: err}
 	}
 }
 
+// decideCmd answers a pending permission request for the given pane.
+func decideCmd(paneID, decision string) tea.Cmd {
+	return func() tea.Msg {
+		err := hookdata.WriteDecision(paneID, decision)
+		return decisionResultMsg{err: err}
+	}
+}
+
+// sendCmd types a prompt into a session's pane and submits it.
+func sendCmd(paneID, text string) tea.Cmd {
+	return func() tea.Msg {
+		return sendResultMsg{err: tmux.SendText(paneID, text)}
+	}
+}
+
+// broadcastCmd types a prompt into each session that is at its prompt,
+// skipping the rest, and reports on every one.
+func broadcastCmd(windows []model.Window, text string) tea.Cmd {
+	return func() tea.Msg {
+		results := make([]sendResult, 0, len(windows))
+		for _, w := range windows {
+			r := sendResult{name: w.Target() + " " + w.Title()}
+			if w.AcceptsInput() {
+				r.err = tmux.SendText(w.PaneID, text)
+			} else {
+				r.err = fmt.Errorf("skipped, %s", strings.ToLower(w.Status.String()))
+			}
+			results = append(results, r)
+		}
+		return broadcastResultMsg{results: results}
+	}
+}
+
+// interruptCmd presses Escape in a session's pane, stopping the turn in
+// progress the way it would at the keyboard.
+func interruptCmd(paneID string) tea.Cmd {
+	return func() tea.Msg {
+		return actionResultMsg{err: tmux.SendKey(paneID, "Escape")}
+	}
+}
+
+// killCmd terminates an agent process.
+func killCmd(pid int) tea.Cmd {
+	return func() tea.Msg {
+		p, err := os.FindProcess(pid)
+		if err == nil {
+			err = p.Signal(syscall.SIGTERM)
+		}
+		return actionResultMsg{err: err}
+	}
+}
+
+// closeWindowCmd closes the tmux window holding a session's pane.
+func closeWindowCmd(paneID string) tea.Cmd {
+	return func() tea.Msg {
+		return actionResultMsg{err: tmux.KillWindow(paneID)}
+	}
+}
+
+// reopenCmd opens a window in dir running argv, to resume an ended
+// session, and switches to it.
+func reopenCmd(dir string, argv []string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := tmux.NewAgentWindow(tmux.WindowOptions{Dir: dir}, argv)
+		return actionResultMsg{err: err}
+	}
+}
+
+// restartCmd replaces whatever runs in a session's pane with argv.
+func restartCmd(paneID, dir string, argv []string) tea.Cmd {
+	return func() tea.Msg {
+		return actionResultMsg{err: tmux.RespawnPane(paneID, dir, argv)}
+	}
+}
+
 // slackNotifyCmd sends a status message to the Slack channel.
 func slackNotifyCmd(enabled bool) tea.Cmd {
 	return func() tea.Msg {
@@ -0,0 +1,21 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirmPrompt asks before a destructive session action: killing the
+// agent, closing its window or restarting it.
+type confirmPrompt struct {
+	question string
+	action   tea.Cmd
+}
+
+// confirmYes runs the action; any other key cancels it.
+var confirmYes = key.NewBinding(key.WithKeys("y", "Y"))
+
+// view renders the question; the caller draws the divider above it.
+func (c *confirmPrompt) view(width int) string {
+	return " " + requestStyle.Render(truncate(c.question, width-5))
+}
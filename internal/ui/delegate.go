@@ -4,38 +4,120 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gxespino/ctree/internal/agent"
+	"github.com/gxespino/ctree/internal/history"
 	"github.com/gxespino/ctree/internal/model"
 )
 
+// hotCPUPercent highlights sessions whose process tree is pegging a core,
+// such as a runaway test runner.
+const hotCPUPercent = 90
+
 // spinnerFrames are braille dot characters that cycle to form a spinner animation.
 var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 
 type windowDelegate struct {
 	spinnerFrame *int
+	showUsage    *bool // add a CPU/memory line under each session
+}
+
+func newWindowDelegate(frame *int, showUsage *bool) windowDelegate {
+	return windowDelegate{spinnerFrame: frame, showUsage: showUsage}
 }
 
-func newWindowDelegate(frame *int) windowDelegate {
-	return windowDelegate{spinnerFrame: frame}
+// Height is a session's row. Shorter rows (tree headers, ended sessions)
+// are padded to it, since the list pages by a fixed row height.
+func (d windowDelegate) Height() int {
+	if d.showUsage != nil && *d.showUsage {
+		return 4
+	}
+	return 3
 }
 
-func (d windowDelegate) Height() int                             { return 5 }
 func (d windowDelegate) Spacing() int                            { return 1 }
 func (d windowDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
 
 func (d windowDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
-	win, ok := item.(model.Window)
+	node, ok := item.(treeItem)
 	if !ok {
 		return
 	}
 
 	isSelected := index == m.Index()
 
-	// Line 1: window number + name + status badge
-	idx := windowNumStyle.Render(fmt.Sprintf("%d", win.WindowIndex))
-	name := nameStyle.Render(win.Title())
+	var content string
+	switch node.kind {
+	case nodeGroup:
+		content = d.renderGroup(m, node)
+	case nodeSubgroup:
+		content = d.renderSubgroup(node)
+	case nodeExited:
+		content = d.renderExited(m, node)
+	default:
+		content = d.renderLeaf(m, node)
+	}
+
+	style := normalItemStyle
+	if isSelected {
+		style = selectedItemStyle
+	} else if node.kind == nodeLeaf && node.window.Status == model.StatusPaused {
+		style = needsInputItemStyle
+	}
+	out := style.Render(content)
+	if pad := d.Height() - 1 - strings.Count(out, "\n"); pad > 0 {
+		out += strings.Repeat("\n", pad)
+	}
+	fmt.Fprint(w, out)
+}
+
+// renderGroup draws a top-level header: a fold marker, the group's name
+// and a rule, with the most urgent status beneath it while collapsed.
+func (d windowDelegate) renderGroup(m list.Model, node treeItem) string {
+	line := groupHeaderStyle.Render(foldMarker(node.collapsed) + " " + node.label)
+	if node.collapsed {
+		line += dimmedStyle.Render(fmt.Sprintf(" (%d)", node.count))
+		if node.window.PaneID != "" {
+			line += "  " + d.badge(node.window)
+		}
+	}
+	ruleWidth := m.Width() - 4
+	if ruleWidth < 3 {
+		ruleWidth = 3
+	}
+	return line + "\n" + groupHeaderStyle.Render(strings.Repeat("─", ruleWidth))
+}
+
+// renderSubgroup draws a nested header: a worktree and its branch, or a
+// window running several agent panes.
+func (d windowDelegate) renderSubgroup(node treeItem) string {
+	line := dimmedStyle.Render(foldMarker(node.collapsed)) + " "
+	if node.num != "" {
+		line += windowNumStyle.Render(node.num) + " "
+	}
+	line += nameStyle.Render(node.label)
+	if node.note != "" {
+		line += " " + branchStyle.Render(node.note)
+	}
+	if node.collapsed {
+		line += "  " + d.badge(node.window)
+	}
+	return line
+}
+
+// foldMarker shows whether a tree header is expanded.
+func foldMarker(collapsed bool) string {
+	if collapsed {
+		return "▸"
+	}
+	return "▾"
+}
+
+// badge renders a window's status, animated while Working.
+func (d windowDelegate) badge(win model.Window) string {
 	statusStyle, ok := statusStyles[win.Status]
 	if !ok {
 		statusStyle = statusStyles[model.StatusUnknown]
@@ -48,11 +130,41 @@ func (d windowDelegate) Render(w io.Writer, m list.Model, index int, item list.I
 		badgeText = "▶ " + badgeText
 	}
 	badge := statusStyle.Render(badgeText)
+	if win.StatusInferred {
+		// Read off the screen, not reported by a hook
+		badge += dimmedStyle.Render(" ≈")
+	}
+	return badge
+}
+
+// renderLeaf draws one agent session, indented under nested headers.
+// Panes sharing a window are numbered window.pane.
+func (d windowDelegate) renderLeaf(m list.Model, node treeItem) string {
+	win := node.window
+	indent := strings.Repeat("  ", node.depth-1)
+	width := m.Width() - len(indent)
+
+	// Line 1: window number + name + status badge
+	num := fmt.Sprintf("%d", win.WindowIndex)
+	if node.paneNum {
+		num = fmt.Sprintf("%d.%d", win.WindowIndex, win.PaneIndex)
+	}
+	idx := windowNumStyle.Render(num)
+	name := nameStyle.Render(win.Title())
+	badge := d.badge(win)
 	line1 := fmt.Sprintf("%s %s  %s", idx, name, badge)
+	if win.Agent != "" && win.Agent != agent.Claude.Name() {
+		line1 = fmt.Sprintf("%s %s %s  %s", idx, name, agentStyle.Render(win.Agent), badge)
+	}
+	if node.marked {
+		line1 = markStyle.Render("●") + " " + line1
+	}
 
-	// Line 2: git branch + diff stats
+	// Line 2: pending request while paused, else git branch + diff stats
 	var line2 string
-	if win.GitBranch != "" {
+	if req := win.Request(); win.Status == model.StatusPaused && req != "" {
+		line2 = requestStyle.Render(" " + truncate(req, width-6))
+	} else if win.GitBranch != "" {
 		line2 = branchStyle.Render(" " + win.GitBranch)
 		if win.GitAdded > 0 || win.GitRemoved > 0 {
 			line2 += "  " + addedStyle.Render(fmt.Sprintf("+%d", win.GitAdded)) +
@@ -62,47 +174,89 @@ func (d windowDelegate) Render(w io.Writer, m list.Model, index int, item list.I
 		line2 = dimmedStyle.Render(" no repo")
 	}
 
-	// Line 3: relative time
+	// Line 3: permission shortcuts while the hook waits on us, the running
+	// tool while working, else relative time
 	var line3 string
-	if !win.LastActivity.IsZero() {
+	if win.AwaitingDecision {
+		line3 = " " + footerKeyStyle.Render("y") + footerDescStyle.Render(" allow ") +
+			footerKeyStyle.Render("d") + footerDescStyle.Render(" deny ") +
+			footerKeyStyle.Render("a") + footerDescStyle.Render(" terminal")
+	} else if tool := win.Request(); win.Status == model.StatusWorking && tool != "" {
+		elapsed := ""
+		if !win.ToolStarted.IsZero() {
+			elapsed = history.FormatDuration(time.Since(win.ToolStarted)) + " "
+		}
+		line3 = " " + dimmedStyle.Render(elapsed) +
+			branchStyle.Render(truncate(tool, width-6-len(elapsed)))
+	} else if !win.LastActivity.IsZero() {
 		line3 = dimmedStyle.Render(" " + model.RelativeTime(win.LastActivity))
 	}
 
-	// Line 0: group header (if first in group) or blank spacer
-	var line0 string
-	isGroupHead := true
-	if index > 0 {
-		if prev, ok := m.Items()[index-1].(model.Window); ok {
-			isGroupHead = prev.WorkingDir != win.WorkingDir
-		}
-	}
-	if isGroupHead {
-		groupName := win.Title()
-		ruleWidth := m.Width() - 4
-		if ruleWidth < 3 {
-			ruleWidth = 3
-		}
-		rule := strings.Repeat("─", ruleWidth)
-		header := groupHeaderStyle.Render(groupName) + "\n" + groupHeaderStyle.Render(rule)
-		if index > 0 {
-			line0 = "\n\n" + header
+	// Line 4 (optional): CPU and memory of Claude and its subprocesses
+	var line4 string
+	if d.showUsage != nil && *d.showUsage {
+		cpu := fmt.Sprintf("cpu %.0f%%", win.CPUPercent)
+		if win.CPUPercent >= hotCPUPercent {
+			cpu = requestStyle.Render(cpu)
 		} else {
-			line0 = header
+			cpu = dimmedStyle.Render(cpu)
 		}
+		line4 = " " + cpu + dimmedStyle.Render("  mem "+formatBytes(win.MemoryRSS))
 	}
 
-	var content string
-	if isGroupHead {
-		content = line0 + "\n" + line1 + "\n" + line2 + "\n" + line3
-	} else {
-		content = line1 + "\n" + line2 + "\n" + line3
+	lines := []string{line1, line2, line3}
+	if line4 != "" {
+		lines = append(lines, line4)
 	}
+	for i := range lines {
+		lines[i] = indent + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
 
-	if isSelected {
-		fmt.Fprint(w, selectedItemStyle.Render(content))
-	} else if win.Status == model.StatusPaused {
-		fmt.Fprint(w, needsInputItemStyle.Render(content))
-	} else {
-		fmt.Fprint(w, normalItemStyle.Render(content))
+// renderExited draws an ended session: where it ran, the status it was
+// last in and what it last showed, and when it exited.
+func (d windowDelegate) renderExited(m list.Model, node treeItem) string {
+	e := node.exited
+	width := m.Width()
+
+	line1 := nameStyle.Render(e.Title()) + "  " + dimmedStyle.Render("was "+e.LastStatus.String())
+	if e.Agent != "" && e.Agent != agent.Claude.Name() {
+		line1 = nameStyle.Render(e.Title()) + " " + agentStyle.Render(e.Agent) + "  " +
+			dimmedStyle.Render("was "+e.LastStatus.String())
+	}
+
+	line2 := dimmedStyle.Render(" no repo")
+	if e.GitBranch != "" {
+		line2 = branchStyle.Render(" " + e.GitBranch)
+	}
+
+	line3 := " " + dimmedStyle.Render("exited "+model.RelativeTime(e.ExitedAt))
+	if e.Message != "" {
+		line3 += dimmedStyle.Render(" · " + truncate(e.Message, width-20))
+	}
+
+	return strings.Join([]string{line1, line2, line3}, "\n")
+}
+
+// formatBytes renders a byte count compactly, e.g. "340M" or "1.2G".
+func formatBytes(n int64) string {
+	const mib = 1 << 20
+	switch {
+	case n >= 10<<30:
+		return fmt.Sprintf("%dG", n>>30)
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	default:
+		return fmt.Sprintf("%dM", (n+mib/2)/mib)
+	}
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis.
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if max < 1 || len(r) <= max {
+		return s
 	}
+	return string(r[:max-1]) + "…"
 }
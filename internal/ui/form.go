@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Fields of the new agent form, in tab order.
+const (
+	fieldRepo = iota
+	fieldBranch
+	fieldBase
+	fieldPrompt
+)
+
+var formLabels = []string{"Repository", "New branch", "Base ref", "Prompt"}
+
+// newAgentRequest is what the new agent form asks for.
+type newAgentRequest struct {
+	repoDir string
+	branch  string // "" runs the agent in repoDir itself, without a worktree
+	base    string // "" for HEAD
+	prompt  string
+}
+
+// newAgentForm collects a repository, branch, base ref and initial
+// prompt for an agent started in a fresh git worktree.
+type newAgentForm struct {
+	inputs []textinput.Model
+	focus  int
+}
+
+// newNewAgentForm opens the form with repoDir filled in.
+func newNewAgentForm(repoDir string, width int) *newAgentForm {
+	placeholders := []string{"~/src/project", "feature-x (empty: no worktree)", "HEAD", "optional"}
+	f := &newAgentForm{}
+	for i := range formLabels {
+		in := textinput.New()
+		in.Prompt = ""
+		in.Placeholder = placeholders[i]
+		in.Width = width - 6
+		f.inputs = append(f.inputs, in)
+	}
+	f.inputs[fieldRepo].SetValue(repoDir)
+
+	// Most of the time the repository is right and a branch is all it needs
+	f.focus = fieldBranch
+	f.inputs[fieldBranch].Focus()
+	return f
+}
+
+// formKeys are the form's own bindings; everything else edits the field.
+var (
+	formNext   = key.NewBinding(key.WithKeys("tab", "down"))
+	formPrev   = key.NewBinding(key.WithKeys("shift+tab", "up"))
+	formSubmit = key.NewBinding(key.WithKeys("enter"))
+	formCancel = key.NewBinding(key.WithKeys("esc"))
+)
+
+// update handles a message while the form is open. Enter on the last
+// field submits; on any other it moves to the next field.
+func (f *newAgentForm) update(msg tea.Msg) (submit, cancel bool, cmd tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, formCancel):
+			return false, true, nil
+		case key.Matches(msg, formSubmit):
+			if f.focus == len(f.inputs)-1 {
+				return true, false, nil
+			}
+			return false, false, f.move(1)
+		case key.Matches(msg, formNext):
+			return false, false, f.move(1)
+		case key.Matches(msg, formPrev):
+			return false, false, f.move(-1)
+		}
+	}
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return false, false, cmd
+}
+
+// move focuses the field delta places away, wrapping around.
+func (f *newAgentForm) move(delta int) tea.Cmd {
+	f.inputs[f.focus].Blur()
+	f.focus = (f.focus + delta + len(f.inputs)) % len(f.inputs)
+	return f.inputs[f.focus].Focus()
+}
+
+// request returns the form's values.
+func (f *newAgentForm) request() newAgentRequest {
+	value := func(i int) string { return strings.TrimSpace(f.inputs[i].Value()) }
+	return newAgentRequest{
+		repoDir: value(fieldRepo),
+		branch:  value(fieldBranch),
+		base:    value(fieldBase),
+		prompt:  value(fieldPrompt),
+	}
+}
+
+// view renders the form, one label and input per field.
+func (f *newAgentForm) view() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("New agent"))
+	b.WriteString("\n")
+	for i, in := range f.inputs {
+		label := branchStyle.Render(formLabels[i])
+		if i == f.focus {
+			label = nameStyle.Render(formLabels[i])
+		}
+		b.WriteString(" " + label + "\n")
+		b.WriteString("  " + in.View() + "\n\n")
+	}
+	return b.String()
+}
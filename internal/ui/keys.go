@@ -4,12 +4,27 @@ import "github.com/charmbracelet/bubbles/key"
 
 type keyMap struct {
 	Enter        key.Binding
+	Collapse     key.Binding
+	Expand       key.Binding
 	JumpUnread   key.Binding
 	NewWorkspace key.Binding
+	Send         key.Binding
+	Mark         key.Binding
+	MarkGroup    key.Binding
+	Interrupt    key.Binding
+	Kill         key.Binding
+	CloseWindow  key.Binding
+	Restart      key.Binding
 	Refresh      key.Binding
 	Preview      key.Binding
+	Timeline     key.Binding
+	Group        key.Binding
+	Usage        key.Binding
 	ToggleBell   key.Binding
 	ToggleSlack  key.Binding
+	Allow        key.Binding
+	Deny         key.Binding
+	AskTerminal  key.Binding
 	Quit         key.Binding
 	Escape       key.Binding
 }
@@ -17,12 +32,27 @@ type keyMap struct {
 func defaultKeyMap() keyMap {
 	return keyMap{
 		Enter:        key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "jump")),
+		Collapse:     key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "collapse")),
+		Expand:       key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "expand")),
 		JumpUnread:   key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next unread")),
 		NewWorkspace: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "new")),
+		Send:         key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "send prompt")),
+		Mark:         key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark")),
+		MarkGroup:    key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "mark group")),
+		Interrupt:    key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "interrupt")),
+		Kill:         key.NewBinding(key.WithKeys("K"), key.WithHelp("K", "kill")),
+		CloseWindow:  key.NewBinding(key.WithKeys("W"), key.WithHelp("W", "close window")),
+		Restart:      key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "restart")),
 		Refresh:      key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
 		Preview:      key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "preview")),
+		Timeline:     key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "timeline")),
+		Group:        key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "grouping")),
+		Usage:        key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "cpu/mem")),
 		ToggleBell:   key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "bell")),
 		ToggleSlack:  key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "slack")),
+		Allow:        key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "allow")),
+		Deny:         key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "deny")),
+		AskTerminal:  key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "answer in terminal")),
 		Quit:         key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
 		Escape:       key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
 	}
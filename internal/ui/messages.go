@@ -3,34 +3,62 @@ package ui
 import (
 	"time"
 
+	"github.com/gxespino/ctree/internal/daemon"
 	"github.com/gxespino/ctree/internal/model"
 )
 
 // tickMsg triggers the next poll cycle.
 type tickMsg time.Time
 
+// spinMsg advances the Working spinner.
+type spinMsg struct{}
+
+// daemonConnectedMsg carries the result of subscribing to the ctree daemon.
+type daemonConnectedMsg struct {
+	sub *daemon.Subscription
+	err error
+}
+
+// daemonSnapshotMsg carries the session list pushed by the daemon.
+type daemonSnapshotMsg struct {
+	windows []model.Window
+	exited  []model.ExitedSession
+	chime   bool
+}
+
+// daemonLostMsg indicates the daemon connection dropped.
+type daemonLostMsg struct{}
+
 // pollResultMsg carries fresh tmux + process detection data.
 type pollResultMsg struct {
 	windows []model.Window
 	err     error
+
+	at   time.Time // when the poll started
+	tick bool      // polled by the tick loop, which waits for this result
 }
 
-// gitResultMsg carries git metadata for a specific window.
+// gitResultMsg carries git metadata for a specific pane.
 type gitResultMsg struct {
-	windowID string
-	branch   string
-	added    int
-	removed  int
-	dirty    bool
-	err      error
+	paneID  string
+	branch  string
+	added   int
+	removed int
+	dirty   bool
+
+	// root and commonDir locate the worktree and repository
+	root      string
+	commonDir string
+
+	err error
 }
 
 // errMsg wraps any error.
 type errMsg struct{ err error }
 
-// jumpedMsg indicates user jumped to a window.
+// jumpedMsg indicates user jumped to an agent pane.
 type jumpedMsg struct {
-	windowID string
+	paneID string
 }
 
 // newWorkspaceResultMsg indicates whether new workspace creation succeeded.
@@ -38,6 +66,35 @@ type newWorkspaceResultMsg struct {
 	err error
 }
 
+// sendResultMsg indicates whether a prompt was typed into a session.
+type sendResultMsg struct {
+	err error
+}
+
+// broadcastResultMsg carries the outcome of sending a prompt to each
+// marked session.
+type broadcastResultMsg struct {
+	results []sendResult
+}
+
+// actionResultMsg indicates whether a session action (interrupt, kill,
+// close, restart) went through.
+type actionResultMsg struct {
+	err error
+}
+
+// decisionResultMsg indicates whether a permission decision was delivered.
+type decisionResultMsg struct {
+	err error
+}
+
+// timelineResultMsg carries a session's formatted status timeline.
+type timelineResultMsg struct {
+	paneID  string
+	content string
+	err     error
+}
+
 // previewResultMsg carries captured pane content for the preview panel.
 type previewResultMsg struct {
 	paneID  string
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// promptBox is the one-line input for typing a follow-up prompt into
+// the selected session, or broadcasting it to the marked ones.
+type promptBox struct {
+	input     textinput.Model
+	paneIDs   []string
+	name      string // who it goes to, e.g. "api" or "3 sessions"
+	broadcast bool   // paneIDs are the marked sessions; report on each
+}
+
+// newPromptBox opens the box for the selected session w.
+func newPromptBox(w model.Window, width int) *promptBox {
+	return &promptBox{input: newPromptInput(width), paneIDs: []string{w.PaneID}, name: w.Title()}
+}
+
+// newBroadcastBox opens the box for the marked sessions.
+func newBroadcastBox(paneIDs []string, width int) *promptBox {
+	name := "1 session"
+	if len(paneIDs) != 1 {
+		name = fmt.Sprintf("%d sessions", len(paneIDs))
+	}
+	return &promptBox{input: newPromptInput(width), paneIDs: paneIDs, name: name, broadcast: true}
+}
+
+// newPromptInput is the text field of both kinds of box.
+func newPromptInput(width int) textinput.Model {
+	in := textinput.New()
+	in.Prompt = "> "
+	in.Placeholder = "follow-up prompt"
+	in.Width = width - 7
+	in.Focus()
+	return in
+}
+
+// update handles a message while the box is open: enter submits, esc
+// cancels and everything else edits the text.
+func (p *promptBox) update(msg tea.Msg) (submit, cancel bool, cmd tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, formCancel):
+			return false, true, nil
+		case key.Matches(msg, formSubmit):
+			return true, false, nil
+		}
+	}
+	p.input, cmd = p.input.Update(msg)
+	return false, false, cmd
+}
+
+// view renders the input line; the caller draws the divider above it.
+func (p *promptBox) view() string {
+	return " " + p.input.View()
+}
+
+// sendResult is the outcome of a broadcast for one session.
+type sendResult struct {
+	name string
+	err  error // nil when sent; otherwise why not
+}
+
+// broadcastReport renders a broadcast's results, a line per session,
+// under a summary for the divider.
+func broadcastReport(results []sendResult) (summary string, lines []string) {
+	sent := 0
+	for _, r := range results {
+		if r.err != nil {
+			lines = append(lines, removedStyle.Render(" ✗ ")+r.name+dimmedStyle.Render(": "+r.err.Error()))
+			continue
+		}
+		sent++
+		lines = append(lines, addedStyle.Render(" ✓ ")+r.name)
+	}
+	return fmt.Sprintf("Sent to %d of %d", sent, len(results)), lines
+}
@@ -59,6 +59,17 @@ var (
 	branchStyle = lipgloss.NewStyle().
 			Foreground(colorGray)
 
+	requestStyle = lipgloss.NewStyle().
+			Foreground(colorOrange)
+
+	agentStyle = lipgloss.NewStyle().
+			Foreground(colorPurple)
+
+	// markStyle flags sessions marked for a broadcast
+	markStyle = lipgloss.NewStyle().
+			Foreground(colorBlue).
+			Bold(true)
+
 	addedStyle = lipgloss.NewStyle().
 			Foreground(colorAddGreen)
 
@@ -0,0 +1,346 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/gxespino/ctree/internal/git"
+	"github.com/gxespino/ctree/internal/model"
+)
+
+// grouping is how the sidebar arranges sessions into a tree.
+type grouping string
+
+const (
+	groupByRepo    grouping = "repo"    // repository → worktree → session
+	groupBySession grouping = "session" // tmux session → window → pane
+	groupByStatus  grouping = "status"  // most urgent status first
+	groupNone      grouping = "none"    // one flat list in tmux order
+)
+
+// groupings is the order the grouping key cycles through.
+var groupings = []grouping{groupByRepo, groupBySession, groupByStatus, groupNone}
+
+// parseGrouping reads a persisted grouping mode, defaulting to by repository.
+func parseGrouping(s string) grouping {
+	for _, g := range groupings {
+		if string(g) == s {
+			return g
+		}
+	}
+	return groupByRepo
+}
+
+// next returns the grouping mode after g.
+func (g grouping) next() grouping {
+	for i, m := range groupings {
+		if m == g {
+			return groupings[(i+1)%len(groupings)]
+		}
+	}
+	return groupByRepo
+}
+
+// nodeKind is the level of a row in the session tree.
+type nodeKind int
+
+const (
+	nodeGroup    nodeKind = iota // a top-level group: repository, tmux session or status
+	nodeSubgroup                 // a worktree, or a window running several agent panes
+	nodeLeaf                     // one agent session
+	nodeExited                   // an ended session, under the recently exited header
+)
+
+// exitedKey is the treeItem key of the recently exited header.
+const exitedKey = "exited"
+
+// treeItem is one row of the sidebar: a group header, a nested header, or
+// a single agent session. Headers carry the most urgent window beneath
+// them, whose status they show when collapsed and which they stand in for
+// when selected (preview, jump).
+type treeItem struct {
+	kind      nodeKind
+	key       string // stable across polls, e.g. "s:<session>", "w:<window id>" or the pane ID
+	label     string
+	num       string // window index shown before a window header's label
+	note      string // dim text after a header's label
+	depth     int
+	window    model.Window
+	count     int // agent sessions beneath a header
+	collapsed bool
+	paneNum   bool // number a leaf window.pane: its window runs several agents
+	marked    bool // a leaf is marked for a broadcast
+
+	panes []string // pane IDs of the sessions beneath a header
+
+	exited model.ExitedSession // an ended session's row
+}
+
+// FilterValue implements bubbles/list.Item for search/filter.
+func (t treeItem) FilterValue() string {
+	switch t.kind {
+	case nodeLeaf:
+		return t.window.FilterValue()
+	case nodeExited:
+		return t.exited.WorkingDir + " " + t.exited.GitBranch + " " + t.exited.Agent
+	}
+	return t.label
+}
+
+// isHeader reports whether the row heads a foldable group.
+func (t treeItem) isHeader() bool {
+	return t.kind == nodeGroup || t.kind == nodeSubgroup
+}
+
+// treeBuilder accumulates the rows of a tree, leaving out the children of
+// collapsed headers.
+type treeBuilder struct {
+	items     []list.Item
+	collapsed map[string]bool
+	marked    map[string]bool
+	panes     map[string]int // agent panes per window ID
+}
+
+// buildTree arranges windows into rows according to mode, followed by
+// the recently exited sessions. marked holds the pane IDs of sessions
+// marked for a broadcast.
+func buildTree(windows []model.Window, exited []model.ExitedSession, mode grouping, collapsed, marked map[string]bool) []list.Item {
+	// tmux order, which every grouping keeps within its groups
+	sorted := make([]model.Window, len(windows))
+	copy(sorted, windows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.SessionName != b.SessionName {
+			return a.SessionName < b.SessionName
+		}
+		if a.WindowIndex != b.WindowIndex {
+			return a.WindowIndex < b.WindowIndex
+		}
+		return a.PaneIndex < b.PaneIndex
+	})
+
+	b := &treeBuilder{collapsed: collapsed, marked: marked, panes: make(map[string]int)}
+	for _, w := range sorted {
+		b.panes[w.WindowID]++
+	}
+
+	switch mode {
+	case groupBySession:
+		b.bySession(sorted)
+	case groupByStatus:
+		b.byStatus(sorted)
+	case groupNone:
+		b.leaves(sorted, 1)
+	default:
+		b.byRepo(sorted)
+	}
+	b.exited(exited)
+	return b.items
+}
+
+// bySession nests windows under their tmux session. A window with one
+// agent pane is a leaf of its own; one with several gets a header row.
+func (b *treeBuilder) bySession(sorted []model.Window) {
+	for _, session := range runs(sorted, func(w model.Window) string { return w.SessionName }) {
+		name := session[0].SessionName
+		if !b.header(treeItem{kind: nodeGroup, key: "s:" + name, label: name}, session) {
+			continue
+		}
+		for _, win := range runs(session, func(w model.Window) string { return w.WindowID }) {
+			if len(win) == 1 {
+				b.leaves(win, 1)
+				continue
+			}
+			item := treeItem{
+				kind:  nodeSubgroup,
+				key:   "w:" + win[0].WindowID,
+				label: win[0].WindowName,
+				num:   fmt.Sprintf("%d", win[0].WindowIndex),
+				note:  fmt.Sprintf("(%d panes)", len(win)),
+				depth: 1,
+			}
+			if b.header(item, win) {
+				b.leaves(win, 2)
+			}
+		}
+	}
+}
+
+// byRepo groups sessions by git repository, so subdirectories of one
+// checkout share a group, with a nested header per worktree when agents
+// work in more than one. Directories outside a repository are grouped by
+// themselves.
+func (b *treeBuilder) byRepo(sorted []model.Window) {
+	repoKey := func(w model.Window) string {
+		if w.GitCommonDir != "" {
+			return "r:" + w.GitCommonDir
+		}
+		return "d:" + w.WorkingDir
+	}
+	repoLabel := func(w model.Window) string {
+		if w.GitCommonDir != "" {
+			return git.RepoName(w.GitCommonDir)
+		}
+		return w.Title()
+	}
+	// The main worktree (whose git directory is its .git) comes first
+	isMain := func(w model.Window) bool {
+		return w.GitRoot == filepath.Dir(w.GitCommonDir)
+	}
+
+	byRepo := make([]model.Window, len(sorted))
+	copy(byRepo, sorted)
+	sort.SliceStable(byRepo, func(i, j int) bool {
+		a, b := byRepo[i], byRepo[j]
+		if la, lb := repoLabel(a), repoLabel(b); la != lb {
+			return la < lb
+		}
+		if ka, kb := repoKey(a), repoKey(b); ka != kb {
+			return ka < kb
+		}
+		if isMain(a) != isMain(b) {
+			return isMain(a)
+		}
+		return a.GitRoot < b.GitRoot
+	})
+
+	for _, repo := range runs(byRepo, repoKey) {
+		if !b.header(treeItem{kind: nodeGroup, key: repoKey(repo[0]), label: repoLabel(repo[0])}, repo) {
+			continue
+		}
+		worktrees := runs(repo, func(w model.Window) string { return w.GitRoot })
+		if len(worktrees) == 1 {
+			b.leaves(repo, 1)
+			continue
+		}
+		for _, wt := range worktrees {
+			item := treeItem{
+				kind:  nodeSubgroup,
+				key:   "t:" + wt[0].GitRoot,
+				label: filepath.Base(wt[0].GitRoot),
+				note:  wt[0].GitBranch,
+				depth: 1,
+			}
+			if b.header(item, wt) {
+				b.leaves(wt, 2)
+			}
+		}
+	}
+}
+
+// byStatus groups sessions by status, most urgent first.
+func (b *treeBuilder) byStatus(sorted []model.Window) {
+	byStatus := make([]model.Window, len(sorted))
+	copy(byStatus, sorted)
+	sort.SliceStable(byStatus, func(i, j int) bool {
+		return urgency(byStatus[i].Status) > urgency(byStatus[j].Status)
+	})
+
+	for _, group := range runs(byStatus, func(w model.Window) string { return w.Status.String() }) {
+		status := group[0].Status.String()
+		if b.header(treeItem{kind: nodeGroup, key: "st:" + status, label: status}, group) {
+			b.leaves(group, 1)
+		}
+	}
+}
+
+// exited appends the recently exited section, most recent first. Its
+// header stands for no running session.
+func (b *treeBuilder) exited(exited []model.ExitedSession) {
+	if len(exited) == 0 {
+		return
+	}
+	item := treeItem{
+		kind:      nodeGroup,
+		key:       exitedKey,
+		label:     "Recently exited",
+		count:     len(exited),
+		collapsed: b.collapsed[exitedKey],
+	}
+	b.items = append(b.items, item)
+	if item.collapsed {
+		return
+	}
+	for _, e := range exited {
+		b.items = append(b.items, treeItem{
+			kind:   nodeExited,
+			key:    "x:" + e.SessionID,
+			depth:  1,
+			exited: e,
+		})
+	}
+}
+
+// header appends a header row summarizing windows and reports whether
+// their rows should follow, i.e. it isn't collapsed.
+func (b *treeBuilder) header(item treeItem, windows []model.Window) bool {
+	item.window = mostUrgent(windows)
+	item.count = len(windows)
+	item.collapsed = b.collapsed[item.key]
+	for _, w := range windows {
+		item.panes = append(item.panes, w.PaneID)
+	}
+	b.items = append(b.items, item)
+	return !item.collapsed
+}
+
+// leaves appends a row per session.
+func (b *treeBuilder) leaves(windows []model.Window, depth int) {
+	for _, w := range windows {
+		b.items = append(b.items, treeItem{
+			kind:    nodeLeaf,
+			key:     w.PaneID,
+			depth:   depth,
+			window:  w,
+			paneNum: b.panes[w.WindowID] > 1,
+			marked:  b.marked[w.PaneID],
+		})
+	}
+}
+
+// runs splits windows into consecutive runs sharing the same key.
+func runs(windows []model.Window, key func(model.Window) string) [][]model.Window {
+	var out [][]model.Window
+	for start := 0; start < len(windows); {
+		end := start + 1
+		for end < len(windows) && key(windows[end]) == key(windows[start]) {
+			end++
+		}
+		out = append(out, windows[start:end])
+		start = end
+	}
+	return out
+}
+
+// mostUrgent returns the window whose status most needs the user, the
+// first one on a tie. windows must not be empty.
+func mostUrgent(windows []model.Window) model.Window {
+	best := windows[0]
+	for _, w := range windows[1:] {
+		if urgency(w.Status) > urgency(best.Status) {
+			best = w
+		}
+	}
+	return best
+}
+
+// urgency ranks statuses for rolling them up into a collapsed header:
+// a question beats unread output, which beats work in progress.
+func urgency(s model.Status) int {
+	switch s {
+	case model.StatusPaused:
+		return 5
+	case model.StatusUnread:
+		return 4
+	case model.StatusWorking:
+		return 3
+	case model.StatusDone:
+		return 2
+	case model.StatusIdle:
+		return 1
+	default:
+		return 0
+	}
+}